@@ -11,68 +11,162 @@
 package container
 
 import (
-	"container/list"
+	"context"
+	"errors"
 	"sync"
+	"time"
 )
 
-type BlockQueue struct {
-	list    *list.List
+// ErrClosed 队列已关闭
+var ErrClosed = errors.New("BlockQueue closed ")
+
+// BlockQueue 元素为interface{}的阻塞队列
+type BlockQueue = BlockQueueOf[interface{}]
+
+// BlockQueueOf 有界阻塞队列
+type BlockQueueOf[T any] struct {
+	blocking
+	items   storage[T]
 	maxSize int
-	lock    *sync.Mutex
-	cond    *sync.Cond
+}
+
+// 队列存储，决定出队顺序
+type storage[T any] interface {
+	len() int
+	push(v T)
+	front() T
+	pop() T
+}
+
+// 阻塞队列共用的加锁、等待与关闭逻辑
+type blocking struct {
+	lock   *sync.Mutex
+	cond   *sync.Cond
+	closed bool
+
+	// 等待中的生产者与消费者数量，有等待者时才需要唤醒
+	producers int
+	consumers int
+}
+
+func newBlocking() blocking {
+	lock := &sync.Mutex{}
+	return blocking{
+		lock: lock,
+		cond: sync.NewCond(lock),
+	}
 }
 
 type OnEnqueque func(data interface{}) bool
 
 func NewBlockQueue(maxSize int) *BlockQueue {
-	lock := &sync.Mutex{}
-	return &BlockQueue{
-		list:    list.New(),
-		maxSize: maxSize,
-		lock:    lock,
-		cond:    sync.NewCond(lock),
+	return NewBlockQueueOf[interface{}](maxSize)
+}
+
+// NewBlockQueueOf 创建最多容纳maxSize个元素的阻塞队列
+func NewBlockQueueOf[T any](maxSize int) *BlockQueueOf[T] {
+	return &BlockQueueOf[T]{
+		blocking: newBlocking(),
+		items:    &ring[T]{},
+		maxSize:  maxSize,
 	}
 }
 
-func (bq *BlockQueue) Enqueue(data interface{}) {
+// Enqueue 入队，队列满时阻塞，队列关闭后数据被丢弃
+func (bq *BlockQueueOf[T]) Enqueue(data T) {
+	bq.EnqueueContext(context.Background(), data)
+}
+
+// EnqueueContext 入队，队列满时阻塞直到有空位、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+func (bq *BlockQueueOf[T]) EnqueueContext(ctx context.Context, data T) error {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 
-	for bq.list.Len() == bq.maxSize {
-		bq.cond.Wait()
+	if bq.closed {
+		return ErrClosed
 	}
-
-	if bq.list.Len() == 0 {
-		defer bq.cond.Broadcast()
+	if err := bq.wait(ctx, bq.full, &bq.producers); err != nil {
+		return err
 	}
 
-	bq.list.PushBack(data)
+	bq.items.push(data)
+	bq.wakeConsumers()
+	return nil
 }
 
-func (bq *BlockQueue) TryEnqueue(data interface{}) bool {
+// EnqueueTimeout 入队，队列满时最多阻塞timeout，超时返回context.DeadlineExceeded
+func (bq *BlockQueueOf[T]) EnqueueTimeout(data T, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return bq.EnqueueContext(ctx, data)
+}
+
+// EnqueueAll 按顺序入队所有元素，每次加锁尽可能多地入队，队列满时阻塞
+// 队列关闭时返回ErrClosed，此时之前的元素可能已经入队
+func (bq *BlockQueueOf[T]) EnqueueAll(items ...T) error {
+	return bq.EnqueueAllContext(context.Background(), items...)
+}
+
+// EnqueueAllContext 与EnqueueAll相同，ctx结束时返回ctx.Err()
+func (bq *BlockQueueOf[T]) EnqueueAllContext(ctx context.Context, items ...T) error {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 
-	for bq.list.Len() == bq.maxSize {
-		return false
+	for len(items) > 0 {
+		if bq.closed {
+			return ErrClosed
+		}
+		if err := bq.wait(ctx, bq.full, &bq.producers); err != nil {
+			return err
+		}
+		n := bq.maxSize - bq.items.len()
+		if bq.maxSize < 0 || n > len(items) {
+			n = len(items)
+		}
+		for _, v := range items[:n] {
+			bq.items.push(v)
+		}
+		items = items[n:]
+		bq.wakeConsumers()
 	}
+	return nil
+}
+
+func (bq *BlockQueueOf[T]) TryEnqueue(data T) bool {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
 
-	if bq.list.Len() == 0 {
-		defer bq.cond.Broadcast()
+	if bq.closed || bq.full() {
+		return false
 	}
 
-	bq.list.PushBack(data)
+	bq.items.push(data)
+	bq.wakeConsumers()
 	return true
 }
 
-func (bq *BlockQueue) First() interface{} {
+// First 获得队首元素，队列为空时返回零值
+// Deprecated: 无法区分队列为空与元素为零值，请使用Peek
+func (bq *BlockQueueOf[T]) First() T {
+	v, _ := bq.Peek()
+	return v
+}
+
+// Peek 获得队首元素但不出队，队列为空时ok为false
+func (bq *BlockQueueOf[T]) Peek() (data T, ok bool) {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
-	elm := bq.list.Front()
-	return elm.Value
+
+	if bq.items.len() == 0 {
+		return data, false
+	}
+	return bq.items.front(), true
 }
 
-func (bq *BlockQueue) WaitOne(onFunc OnEnqueque) {
+// WaitOne 等待队首元素并调用onFunc，onFunc返回true时出队
+// 队列关闭并且为空时直接返回
+func (bq *BlockQueueOf[T]) WaitOne(onFunc func(data T) bool) {
 	if onFunc == nil {
 		return
 	}
@@ -80,31 +174,234 @@ func (bq *BlockQueue) WaitOne(onFunc OnEnqueque) {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 
-	for bq.list.Len() == 0 {
-		bq.cond.Wait()
+	if bq.wait(context.Background(), bq.empty, &bq.consumers) != nil {
+		return
 	}
 
-	if bq.list.Len() == bq.maxSize {
-		defer bq.cond.Broadcast()
+	if onFunc(bq.items.front()) {
+		bq.items.pop()
+		bq.wakeProducers()
 	}
-	elm := bq.list.Front()
-	if onFunc(elm.Value) {
-		bq.list.Remove(elm)
+}
+
+// Dequeue 出队，队列为空时阻塞，队列关闭并且为空时返回零值
+func (bq *BlockQueueOf[T]) Dequeue() T {
+	v, _ := bq.DequeueContext(context.Background())
+	return v
+}
+
+// DequeueContext 出队，队列为空时阻塞直到有数据、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+// 队列关闭后仍然可以取出剩余的数据，取完后返回ErrClosed
+func (bq *BlockQueueOf[T]) DequeueContext(ctx context.Context) (T, error) {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	if err := bq.wait(ctx, bq.empty, &bq.consumers); err != nil {
+		var zero T
+		return zero, err
 	}
+
+	v := bq.items.pop()
+	bq.wakeProducers()
+	return v, nil
+}
+
+// DequeueTimeout 出队，队列为空时最多阻塞timeout，超时返回context.DeadlineExceeded
+func (bq *BlockQueueOf[T]) DequeueTimeout(timeout time.Duration) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return bq.DequeueContext(ctx)
 }
 
-func (bq *BlockQueue) Dequeue() interface{} {
+// DrainTo 不阻塞地取出最多max个元素（max小于等于0时取出全部）追加到buf并返回
+func (bq *BlockQueueOf[T]) DrainTo(buf []T, max int) []T {
 	bq.lock.Lock()
 	defer bq.lock.Unlock()
 
-	for bq.list.Len() == 0 {
+	return bq.drain(buf, max)
+}
+
+// DequeueBatch 等待队列中至少有min个元素后一次取出最多max个元素
+// min小于1时为1，大于队列容量时为队列容量；max小于min时为min
+// 超过timeout（小于0时不超时）仍不足min个时取出已有的元素，队列为空则返回context.DeadlineExceeded；
+// 队列关闭时取出剩余的元素，队列为空则返回ErrClosed
+func (bq *BlockQueueOf[T]) DequeueBatch(min, max int, timeout time.Duration) ([]T, error) {
+	ctx := context.Background()
+	if timeout >= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return bq.DequeueBatchContext(ctx, min, max)
+}
+
+// DequeueBatchContext 与DequeueBatch相同，ctx结束时取出已有的元素，队列为空则返回ctx.Err()
+func (bq *BlockQueueOf[T]) DequeueBatchContext(ctx context.Context, min, max int) ([]T, error) {
+	if bq.maxSize > 0 && min > bq.maxSize {
+		min = bq.maxSize
+	}
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	err := bq.wait(ctx, func() bool {
+		return bq.items.len() < min
+	}, &bq.consumers)
+	if err != nil && bq.items.len() == 0 {
+		return nil, err
+	}
+	return bq.drain(make([]T, 0, max), max), nil
+}
+
+// 需要在持有锁时调用
+func (bq *BlockQueueOf[T]) drain(buf []T, max int) []T {
+	n := bq.items.len()
+	if max > 0 && max < n {
+		n = max
+	}
+	for i := 0; i < n; i++ {
+		buf = append(buf, bq.items.pop())
+	}
+	if n > 0 {
+		bq.wakeProducers()
+	}
+	return buf
+}
+
+// Len 队列中元素个数
+func (bq *BlockQueueOf[T]) Len() int {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	return bq.items.len()
+}
+
+// Close 关闭队列并唤醒所有阻塞的生产者与消费者，之后入队返回ErrClosed，出队取完剩余数据后返回ErrClosed
+func (bq *blocking) Close() error {
+	bq.lock.Lock()
+	defer bq.lock.Unlock()
+
+	if !bq.closed {
+		bq.closed = true
+		bq.cond.Broadcast()
+	}
+	return nil
+}
+
+func (bq *BlockQueueOf[T]) full() bool {
+	return bq.items.len() == bq.maxSize
+}
+
+func (bq *BlockQueueOf[T]) empty() bool {
+	return bq.items.len() == 0
+}
+
+func (bq *blocking) wakeConsumers() {
+	if bq.consumers > 0 {
+		bq.cond.Broadcast()
+	}
+}
+
+func (bq *blocking) wakeProducers() {
+	if bq.producers > 0 {
+		bq.cond.Broadcast()
+	}
+}
+
+// 在持有锁时等待blocked不成立，队列关闭返回ErrClosed，ctx结束返回ctx.Err()
+// waiting为等待者计数，等待期间加1
+func (bq *blocking) wait(ctx context.Context, blocked func() bool, waiting *int) error {
+	var stop func()
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for blocked() {
+		if bq.closed {
+			return ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop == nil {
+			stop = bq.wakeOnDone(ctx)
+		}
+		*waiting++
 		bq.cond.Wait()
+		*waiting--
 	}
+	return nil
+}
 
-	if bq.list.Len() == bq.maxSize {
-		defer bq.cond.Broadcast()
+// sync.Cond无法与ctx一起等待，ctx结束时唤醒所有等待者重新检查
+func (bq *blocking) wakeOnDone(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
 	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			bq.lock.Lock()
+			bq.cond.Broadcast()
+			bq.lock.Unlock()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
 
-	elm := bq.list.Front()
-	return bq.list.Remove(elm)
+// 环形缓冲区，容量按需倍增
+type ring[T any] struct {
+	buf  []T
+	head int
+	size int
+}
+
+func (r *ring[T]) len() int {
+	return r.size
+}
+
+func (r *ring[T]) push(v T) {
+	if r.size == len(r.buf) {
+		r.grow()
+	}
+	r.buf[(r.head+r.size)%len(r.buf)] = v
+	r.size++
+}
+
+func (r *ring[T]) front() T {
+	return r.buf[r.head]
+}
+
+func (r *ring[T]) pop() T {
+	var zero T
+	v := r.buf[r.head]
+	r.buf[r.head] = zero
+	r.head = (r.head + 1) % len(r.buf)
+	r.size--
+	return v
+}
+
+func (r *ring[T]) grow() {
+	n := len(r.buf) * 2
+	if n == 0 {
+		n = 8
+	}
+	buf := make([]T, n)
+	for i := 0; i < r.size; i++ {
+		buf[i] = r.buf[(r.head+i)%len(r.buf)]
+	}
+	r.buf = buf
+	r.head = 0
 }
@@ -0,0 +1,203 @@
+/**
+ * Copyright (C) 2018, Xiongfa Li.
+ * All right reserved.
+ * @author xiongfa.li
+ * @version V1.0
+ * Description:
+ */
+
+package container
+
+import (
+	"context"
+	"time"
+)
+
+// DelayQueue 有界延时阻塞队列，元素到期后才能出队，按到期时间顺序出队，到期时间相同时先入队的先出队
+// 阻塞、超时与关闭语义与BlockQueueOf相同，关闭后不再等待未到期的元素
+type DelayQueue[T any] struct {
+	blocking
+	items   priorityHeap[delayed[T]]
+	maxSize int
+}
+
+type delayed[T any] struct {
+	value T
+	at    time.Time
+}
+
+// NewDelayQueue 创建最多容纳maxSize个元素（小于0时不限制）的延时阻塞队列
+func NewDelayQueue[T any](maxSize int) *DelayQueue[T] {
+	return &DelayQueue[T]{
+		blocking: newBlocking(),
+		items: priorityHeap[delayed[T]]{
+			less: func(a, b delayed[T]) bool {
+				return a.at.Before(b.at)
+			},
+		},
+		maxSize: maxSize,
+	}
+}
+
+// Enqueue 入队，delay之后可以出队，队列满时阻塞，队列关闭后数据被丢弃
+func (dq *DelayQueue[T]) Enqueue(data T, delay time.Duration) {
+	dq.EnqueueAtContext(context.Background(), data, time.Now().Add(delay))
+}
+
+// EnqueueContext 入队，delay之后可以出队，队列满时阻塞直到有空位、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+func (dq *DelayQueue[T]) EnqueueContext(ctx context.Context, data T, delay time.Duration) error {
+	return dq.EnqueueAtContext(ctx, data, time.Now().Add(delay))
+}
+
+// EnqueueAtContext 入队，at之后可以出队，其他与EnqueueContext相同
+func (dq *DelayQueue[T]) EnqueueAtContext(ctx context.Context, data T, at time.Time) error {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	if dq.closed {
+		return ErrClosed
+	}
+	if err := dq.wait(ctx, dq.full, &dq.producers); err != nil {
+		return err
+	}
+
+	dq.items.push(delayed[T]{value: data, at: at})
+	dq.wakeConsumers()
+	return nil
+}
+
+// TryEnqueue 队列未满时入队，delay之后可以出队
+func (dq *DelayQueue[T]) TryEnqueue(data T, delay time.Duration) bool {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	if dq.closed || dq.full() {
+		return false
+	}
+
+	dq.items.push(delayed[T]{value: data, at: time.Now().Add(delay)})
+	dq.wakeConsumers()
+	return true
+}
+
+// Peek 获得最先到期的元素及其到期时间但不出队，队列为空时ok为false
+func (dq *DelayQueue[T]) Peek() (data T, at time.Time, ok bool) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	if dq.items.len() == 0 {
+		return data, at, false
+	}
+	e := dq.items.front()
+	return e.value, e.at, true
+}
+
+// Dequeue 出队，没有到期的元素时阻塞，队列关闭并且没有到期的元素时返回零值
+func (dq *DelayQueue[T]) Dequeue() T {
+	v, _ := dq.DequeueContext(context.Background())
+	return v
+}
+
+// DequeueContext 出队，没有到期的元素时阻塞直到有元素到期、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+// 队列关闭后仍然可以取出已到期的元素，没有已到期的元素时返回ErrClosed
+func (dq *DelayQueue[T]) DequeueContext(ctx context.Context) (T, error) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	var timer *time.Timer
+	var stop func()
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		if stop != nil {
+			stop()
+		}
+	}()
+	for {
+		wakeAt, ready := dq.ready()
+		if ready {
+			break
+		}
+		var zero T
+		if dq.closed {
+			return zero, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		if stop == nil {
+			stop = dq.wakeOnDone(ctx)
+		}
+		// 最先到期的元素到期时唤醒，新入队的元素可能更早到期，每次等待前重新设置
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		if !wakeAt.IsZero() {
+			timer = time.AfterFunc(time.Until(wakeAt), func() {
+				dq.lock.Lock()
+				dq.cond.Broadcast()
+				dq.lock.Unlock()
+			})
+		}
+		dq.consumers++
+		dq.cond.Wait()
+		dq.consumers--
+	}
+
+	v := dq.items.pop().value
+	dq.wakeProducers()
+	return v, nil
+}
+
+// DequeueTimeout 出队，没有到期的元素时最多阻塞timeout，超时返回context.DeadlineExceeded
+func (dq *DelayQueue[T]) DequeueTimeout(timeout time.Duration) (T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return dq.DequeueContext(ctx)
+}
+
+// DrainTo 不阻塞地取出最多max个已到期的元素（max小于等于0时取出全部已到期元素）追加到buf并返回
+func (dq *DelayQueue[T]) DrainTo(buf []T, max int) []T {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	n := 0
+	for max <= 0 || n < max {
+		if _, ready := dq.ready(); !ready {
+			break
+		}
+		buf = append(buf, dq.items.pop().value)
+		n++
+	}
+	if n > 0 {
+		dq.wakeProducers()
+	}
+	return buf
+}
+
+// Len 队列中元素个数，包含未到期的元素
+func (dq *DelayQueue[T]) Len() int {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+
+	return dq.items.len()
+}
+
+func (dq *DelayQueue[T]) full() bool {
+	return dq.items.len() == dq.maxSize
+}
+
+// 队首元素是否到期，未到期时返回到期时间，队列为空时返回零值
+func (dq *DelayQueue[T]) ready() (wakeAt time.Time, ready bool) {
+	if dq.items.len() == 0 {
+		return time.Time{}, false
+	}
+	at := dq.items.front().at
+	if !at.After(time.Now()) {
+		return time.Time{}, true
+	}
+	return at, false
+}
@@ -0,0 +1,566 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package diskqueue 基于磁盘的持久化FIFO队列，可以作为不依赖消息中间件的本地outbox
+//
+// 消息按顺序追加写入目录中的段文件，每条消息有从0开始递增的偏移量。
+// 消费者处理完消息后调用Ack确认，所有已确认的连续前缀作为提交偏移量保存，
+// 完全被提交的段文件会被删除。进程重启后从提交偏移量开始重新投递所有未确认的消息，
+// 因此消息至少被投递一次，消费者需要保证处理是幂等的。
+package diskqueue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSegmentSize 默认段文件大小，超过后写入新的段文件
+	DefaultSegmentSize = 64 * 1024 * 1024
+
+	// DefaultMaxMessageSize 默认单条消息最大长度
+	DefaultMaxMessageSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrClosed 队列已关闭
+	ErrClosed = errors.New("diskqueue: queue closed ")
+	// ErrTooLarge 消息超过最大长度
+	ErrTooLarge = errors.New("diskqueue: message too large ")
+	// ErrInvalidOffset 确认的偏移量还未出队
+	ErrInvalidOffset = errors.New("diskqueue: offset has not been dequeued ")
+)
+
+// Message 队列中的消息
+type Message struct {
+	// 偏移量，用于Ack
+	Offset uint64
+	Data   []byte
+}
+
+// Queue 持久化队列，线程安全
+type Queue struct {
+	dir            string
+	segmentSize    int64
+	maxMessageSize int
+	sync           bool
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	closed bool
+
+	// 所有段文件的起始偏移量，按偏移量排序
+	segments []uint64
+
+	writer      *os.File
+	writeSize   int64
+	writeOffset uint64
+
+	reader     *bufio.Reader
+	readFile   *os.File
+	readBase   uint64
+	readOffset uint64
+
+	// 小于committed的消息都已确认
+	committed uint64
+	acked     map[uint64]struct{}
+}
+
+type Opt func(*Queue)
+
+// Open 打开dir中的队列，目录不存在时创建
+// 最后一个段文件末尾不完整的记录（写入时崩溃）会被截断，记录损坏时返回*CorruptError，
+// 从提交偏移量开始重新投递未确认的消息
+func Open(dir string, opts ...Opt) (*Queue, error) {
+	q := &Queue{
+		dir:            dir,
+		segmentSize:    DefaultSegmentSize,
+		maxMessageSize: DefaultMaxMessageSize,
+		acked:          map[uint64]struct{}{},
+	}
+	q.cond = sync.NewCond(&q.lock)
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	committed, err := readCommit(dir)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		segments = []uint64{committed}
+	}
+	q.segments = segments
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+
+	// 未sync的数据在机器崩溃后可能丢失，此时提交偏移量可能超过已写入的消息
+	if committed > q.writeOffset {
+		committed = q.writeOffset
+	}
+	if committed < q.segments[0] {
+		committed = q.segments[0]
+	}
+	q.committed = committed
+	q.readOffset = committed
+	if err := q.cleanup(); err != nil {
+		q.writer.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// 截断最后一个段文件末尾不完整的记录并打开写入，记录损坏时返回*CorruptError
+func (q *Queue) recover() error {
+	base := q.segments[len(q.segments)-1]
+	path := q.segmentPath(base)
+	count, size, err := scanSegment(path, q.maxMessageSize)
+	if err != nil && err != io.ErrUnexpectedEOF && !os.IsNotExist(err) {
+		if err == ErrCorrupt {
+			return &CorruptError{Segment: path, Offset: base + count, Err: err}
+		}
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if q.sync {
+		if err := syncDir(q.dir); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, 0); err != nil {
+		f.Close()
+		return err
+	}
+	q.writer = f
+	q.writeSize = size
+	q.writeOffset = base + count
+	return nil
+}
+
+// Enqueue 追加一条消息，返回消息的偏移量
+// 配置OptSync时写入后调用fsync，返回后即使机器崩溃消息也不会丢失
+func (q *Queue) Enqueue(data []byte) (uint64, error) {
+	if len(data) > q.maxMessageSize {
+		return 0, ErrTooLarge
+	}
+	record := encodeRecord(data)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return 0, ErrClosed
+	}
+	if q.writeSize > 0 && q.writeSize+int64(len(record)) > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := q.writer.Write(record); err != nil {
+		q.discardTail()
+		return 0, err
+	}
+	if q.sync {
+		if err := q.writer.Sync(); err != nil {
+			// 返回错误的消息不能在之后被投递
+			q.discardTail()
+			return 0, err
+		}
+	}
+	offset := q.writeOffset
+	q.writeOffset++
+	q.writeSize += int64(len(record))
+	q.cond.Broadcast()
+	return offset, nil
+}
+
+// 尽量去掉writeSize之后写入的数据，失败时由下次Open时截断，需要在持有锁时调用
+func (q *Queue) discardTail() {
+	q.writer.Truncate(q.writeSize)
+	q.writer.Seek(q.writeSize, 0)
+}
+
+// 关闭当前段文件并创建新的段文件，需要在持有锁时调用
+// Open只检查最后一个段文件，无论是否配置OptSync都在关闭前调用fsync，避免机器崩溃后已关闭的段文件不完整
+func (q *Queue) rotate() error {
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.segmentPath(q.writeOffset), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if q.sync {
+		if err := syncDir(q.dir); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	q.writer.Close()
+	q.writer = f
+	q.writeSize = 0
+	q.segments = append(q.segments, q.writeOffset)
+	return nil
+}
+
+// Dequeue 取出下一条消息，队列为空时阻塞直到有消息或者队列关闭（返回ErrClosed）
+func (q *Queue) Dequeue() (Message, error) {
+	return q.DequeueContext(context.Background())
+}
+
+// DequeueTimeout 取出下一条消息，队列为空时最多阻塞timeout，超时返回context.DeadlineExceeded
+func (q *Queue) DequeueTimeout(timeout time.Duration) (Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return q.DequeueContext(ctx)
+}
+
+// DequeueContext 取出下一条消息，队列为空时阻塞直到有消息、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+// 取出的消息需要调用Ack确认，未确认的消息在重新打开队列后再次投递
+// 消息无法读取时返回*CorruptError，之后每次出队都返回该错误，可以调用SkipSegment跳过损坏的消息
+func (q *Queue) DequeueContext(ctx context.Context) (Message, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var stop func()
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for q.readOffset == q.writeOffset {
+		if q.closed {
+			return Message{}, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+		if stop == nil {
+			stop = q.wakeOnDone(ctx)
+		}
+		q.cond.Wait()
+	}
+	if q.closed {
+		return Message{}, ErrClosed
+	}
+	return q.readNext()
+}
+
+// TryDequeue 不阻塞地取出下一条消息，队列为空时ok为false
+func (q *Queue) TryDequeue() (msg Message, ok bool, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return msg, false, ErrClosed
+	}
+	if q.readOffset == q.writeOffset {
+		return msg, false, nil
+	}
+	msg, err = q.readNext()
+	return msg, err == nil, err
+}
+
+// 读取readOffset处的消息，需要在持有锁时调用
+func (q *Queue) readNext() (Message, error) {
+	if err := q.seekReader(); err != nil {
+		return Message{}, err
+	}
+	data, err := readRecord(q.reader, q.maxMessageSize)
+	if err != nil {
+		// 读取失败时下次重新定位
+		q.closeReader()
+		return Message{}, corruptError(q.segmentPath(q.readBase), q.readOffset, err)
+	}
+	msg := Message{Offset: q.readOffset, Data: data}
+	q.readOffset++
+	return msg, nil
+}
+
+// 打开readOffset所在的段文件并定位到readOffset，需要在持有锁时调用
+func (q *Queue) seekReader() error {
+	base := q.segments[0]
+	for _, b := range q.segments {
+		if b > q.readOffset {
+			break
+		}
+		base = b
+	}
+	if q.readFile != nil && base == q.readBase {
+		return nil
+	}
+	q.closeReader()
+
+	path := q.segmentPath(base)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for i := base; i < q.readOffset; i++ {
+		if _, err := readRecord(r, q.maxMessageSize); err != nil {
+			f.Close()
+			return corruptError(path, i, err)
+		}
+	}
+	q.readFile = f
+	q.reader = r
+	q.readBase = base
+	return nil
+}
+
+// readOffset小于writeOffset时消息一定存在，数据提前结束或者损坏都作为*CorruptError返回
+func corruptError(path string, offset uint64, err error) error {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return &CorruptError{Segment: path, Offset: offset, Err: io.ErrUnexpectedEOF}
+	case ErrCorrupt:
+		return &CorruptError{Segment: path, Offset: offset, Err: err}
+	}
+	return err
+}
+
+// SkipSegment 跳过readOffset所在段文件中所有未出队的消息，返回跳过的消息数量
+// 用于出队返回*CorruptError后继续消费后面的消息：损坏的记录之后的消息无法定位，所以整个段文件剩余的消息都被跳过，
+// 被跳过的消息视为已确认。readOffset在当前写入的段文件中时，之后入队的消息写入新的段文件
+func (q *Queue) SkipSegment() (uint64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return 0, ErrClosed
+	}
+	if q.readOffset == q.writeOffset {
+		return 0, nil
+	}
+	next, found := uint64(0), false
+	for _, b := range q.segments {
+		if b > q.readOffset {
+			next, found = b, true
+			break
+		}
+	}
+	if !found {
+		if err := q.rotate(); err != nil {
+			return 0, err
+		}
+		next = q.writeOffset
+	}
+	q.closeReader()
+	skipped := next - q.readOffset
+	for o := q.readOffset; o < next; o++ {
+		q.acked[o] = struct{}{}
+	}
+	q.readOffset = next
+	return skipped, q.commit(q.ackedPrefix(q.committed))
+}
+
+func (q *Queue) closeReader() {
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+		q.reader = nil
+	}
+}
+
+// Ack 确认已处理的消息，确认可以乱序，所有已确认的连续前缀会被提交
+func (q *Queue) Ack(offset uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if offset >= q.readOffset {
+		return ErrInvalidOffset
+	}
+	if offset < q.committed {
+		return nil
+	}
+	q.acked[offset] = struct{}{}
+	return q.commit(q.ackedPrefix(q.committed))
+}
+
+// Commit 确认偏移量小于等于offset的所有消息
+func (q *Queue) Commit(offset uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if offset >= q.readOffset {
+		return ErrInvalidOffset
+	}
+	if offset < q.committed {
+		return nil
+	}
+	for o := range q.acked {
+		if o <= offset {
+			delete(q.acked, o)
+		}
+	}
+	return q.commit(q.ackedPrefix(offset + 1))
+}
+
+// 从committed开始移除连续的已确认偏移量，返回新的提交偏移量，需要在持有锁时调用
+func (q *Queue) ackedPrefix(committed uint64) uint64 {
+	for {
+		if _, ok := q.acked[committed]; !ok {
+			return committed
+		}
+		delete(q.acked, committed)
+		committed++
+	}
+}
+
+// 保存提交偏移量并删除已完全提交的段文件，需要在持有锁时调用
+func (q *Queue) commit(committed uint64) error {
+	if committed == q.committed {
+		return nil
+	}
+	if err := writeCommit(q.dir, committed, q.sync); err != nil {
+		return err
+	}
+	q.committed = committed
+	return q.cleanup()
+}
+
+// 删除所有消息都已提交的段文件，当前写入的段文件不会被删除，需要在持有锁时调用
+func (q *Queue) cleanup() error {
+	for len(q.segments) > 1 && q.segments[1] <= q.committed {
+		base := q.segments[0]
+		if q.readFile != nil && q.readBase == base {
+			q.closeReader()
+		}
+		if err := os.Remove(q.segmentPath(base)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+// Len 未出队的消息数量
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return int(q.writeOffset - q.readOffset)
+}
+
+// Committed 提交偏移量，小于该偏移量的消息都已确认
+func (q *Queue) Committed() uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.committed
+}
+
+// Close 关闭队列并唤醒所有阻塞的消费者，已出队但未确认的消息在重新打开后再次投递
+func (q *Queue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.cond.Broadcast()
+	q.closeReader()
+
+	var err error
+	if q.sync {
+		err = q.writer.Sync()
+	}
+	if e := q.writer.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+func (q *Queue) segmentPath(base uint64) string {
+	return filepath.Join(q.dir, segmentName(base))
+}
+
+// sync.Cond无法与ctx一起等待，ctx结束时唤醒所有等待者重新检查
+func (q *Queue) wakeOnDone(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.lock.Lock()
+			q.cond.Broadcast()
+			q.lock.Unlock()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// OptSegmentSize 配置段文件大小（默认DefaultSegmentSize），超过后写入新的段文件
+func OptSegmentSize(size int64) Opt {
+	return func(q *Queue) {
+		if size > 0 {
+			q.segmentSize = size
+		}
+	}
+}
+
+// OptMaxMessageSize 配置单条消息最大长度（默认DefaultMaxMessageSize），打开已有队列时需要与写入时的配置一致
+func OptMaxMessageSize(size int) Opt {
+	return func(q *Queue) {
+		if size > 0 {
+			q.maxMessageSize = size
+		}
+	}
+}
+
+// OptSync 配置每次写入消息与提交偏移量后是否调用fsync（默认false），创建段文件与提交偏移量文件后同时对目录调用fsync
+// 不调用fsync时进程崩溃不会丢失数据，但是机器崩溃可能丢失最近写入的消息
+func OptSync(sync bool) Opt {
+	return func(q *Queue) {
+		q.sync = sync
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package diskqueue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segmentExt = ".seg"
+	commitFile = "commit"
+
+	// 记录头：4字节数据长度 + 4字节CRC32
+	headerSize = 8
+)
+
+// ErrCorrupt 记录损坏（长度非法或者校验失败）
+var ErrCorrupt = errors.New("diskqueue: corrupt record ")
+
+// CorruptError 段文件中偏移量为Offset的消息无法读取，Err为ErrCorrupt或者io.ErrUnexpectedEOF（记录不完整）
+type CorruptError struct {
+	Segment string
+	Offset  uint64
+	Err     error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("diskqueue: read message %d in segment %s failed: %v", e.Offset, e.Segment, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// 段文件以第一条消息的偏移量命名
+func segmentName(base uint64) string {
+	return fmt.Sprintf("%020d%s", base, segmentExt)
+}
+
+// 列出目录中所有段文件的起始偏移量，按偏移量排序
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, base)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i] < ret[j]
+	})
+	return ret, nil
+}
+
+func encodeRecord(data []byte) []byte {
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], data)
+	return buf
+}
+
+// 读取一条记录，数据结束返回io.EOF，记录不完整返回io.ErrUnexpectedEOF，记录损坏返回ErrCorrupt
+func readRecord(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if int64(size) > int64(maxSize) {
+		return nil, ErrCorrupt
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// 扫描段文件，返回完整记录的数量及其占用的字节数
+// 崩溃时最后一条记录可能不完整，此时返回已扫描的完整记录及io.ErrUnexpectedEOF，遇到损坏的记录时返回ErrCorrupt
+func scanSegment(path string, maxSize int) (count uint64, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		data, err := readRecord(r, maxSize)
+		if err != nil {
+			if err == io.EOF {
+				return count, size, nil
+			}
+			return count, size, err
+		}
+		count++
+		size += int64(headerSize + len(data))
+	}
+}
+
+// 读取提交偏移量，文件不存在时返回0
+func readCommit(dir string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, commitFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(data) != 12 || crc32.ChecksumIEEE(data[:8]) != binary.BigEndian.Uint32(data[8:]) {
+		return 0, fmt.Errorf("diskqueue: corrupt commit file ")
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// 写入临时文件后重命名，保证提交偏移量文件总是完整的，sync为true时对文件及目录调用fsync
+func writeCommit(dir string, offset uint64, sync bool) error {
+	var buf [12]byte
+	binary.BigEndian.PutUint64(buf[:], offset)
+	binary.BigEndian.PutUint32(buf[8:], crc32.ChecksumIEEE(buf[:8]))
+
+	tmp := filepath.Join(dir, commitFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, commitFile)); err != nil {
+		return err
+	}
+	if sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// 对目录调用fsync，保证文件的创建与重命名在机器崩溃后不会丢失
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keyhash 分片容器使用的key hash函数
+package keyhash
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+// Of 计算任意comparable key的hash，供泛型容器作为默认hasher
+func Of[K comparable](key K) uint64 {
+	return Hash(key)
+}
+
+// Hash 计算key的hash，与Go map判断key相等的规则一致：
+// 整数使用Mix64，字符串使用FNV-1a，浮点数0.0与-0.0相同，指针、chan按地址计算，
+// 接口按动态值计算，结构体、数组组合每个字段（元素）的hash
+func Hash(key interface{}) uint64 {
+	switch v := key.(type) {
+	case string:
+		return hashString(v)
+	case int:
+		return Mix64(uint64(v))
+	case int64:
+		return Mix64(uint64(v))
+	case int32:
+		return Mix64(uint64(v))
+	case uint:
+		return Mix64(uint64(v))
+	case uint64:
+		return Mix64(v)
+	case uint32:
+		return Mix64(uint64(v))
+	case float64:
+		return hashFloat(v)
+	default:
+		return hashValue(reflect.ValueOf(key))
+	}
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		// -0.0 == 0.0
+		return Mix64(0)
+	}
+	return Mix64(math.Float64bits(f))
+}
+
+// 组合多个hash值
+func combine(h, x uint64) uint64 {
+	return Mix64(h ^ (x + 0x9e3779b97f4a7c15 + (h << 6) + (h >> 2)))
+}
+
+func hashValue(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Bool:
+		if v.Bool() {
+			return Mix64(1)
+		}
+		return Mix64(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Mix64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Mix64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return combine(hashFloat(real(c)), hashFloat(imag(c)))
+	case reflect.String:
+		return hashString(v.String())
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return Mix64(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashValue(v.Elem())
+	case reflect.Struct:
+		var h uint64
+		for i := 0; i < v.NumField(); i++ {
+			h = combine(h, hashValue(v.Field(i)))
+		}
+		return h
+	case reflect.Array:
+		var h uint64
+		for i := 0; i < v.Len(); i++ {
+			h = combine(h, hashValue(v.Index(i)))
+		}
+		return h
+	default:
+		// 不可比较的类型不能作为map的key
+		panic(fmt.Sprintf("keyhash: unhashable type %s", v.Type()))
+	}
+}
+
+// Mix64 64位整数混淆（MurmurHash3 fmix64），使相近的整数分布均匀
+func Mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package singleflight 合并同一个key的并发加载，供lru与recycleMap的GetOrLoad使用
+package singleflight
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPanic 加载函数发生panic，等待同一次加载的其他调用者返回该错误（包装了panic的值）
+var ErrPanic = errors.New("loader panic")
+
+type call[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+	// 加载函数发生panic时的值
+	panicked   bool
+	panicValue interface{}
+	// 加载期间key被修改或删除，加载结果不能再放入缓存
+	stale bool
+}
+
+// Group 同一时刻每个key最多只有一次加载在执行，零值可以直接使用
+type Group[K comparable, V any] struct {
+	lock  sync.Mutex
+	calls map[K]*call[V]
+	// 正在执行的加载数量，为0时Forget不需要加锁
+	inflight int32
+
+	loads      uint64
+	loadErrors uint64
+}
+
+// Do 执行加载，key已经在加载中时等待并返回该次加载的结果
+// fn的参数stale返回加载期间key是否被Forget，需要在与Forget互斥的锁内调用，以保证检查后到放入缓存之前key不会被修改
+// fn发生panic时在当前调用者中重新panic，等待的调用者返回ErrPanic
+func (g *Group[K, V]) Do(key K, fn func(stale func() bool) (V, error)) (V, error) {
+	g.lock.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := g.add(key)
+	g.lock.Unlock()
+
+	g.run(key, c, fn)
+	if c.panicked {
+		panic(c.panicValue)
+	}
+	return c.val, c.err
+}
+
+// DoAsync 异步加载，key已经在加载中时直接返回，fn发生panic时只记录为加载失败
+func (g *Group[K, V]) DoAsync(key K, fn func(stale func() bool) (V, error)) {
+	g.lock.Lock()
+	if _, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		return
+	}
+	c := g.add(key)
+	g.lock.Unlock()
+
+	go g.run(key, c, fn)
+}
+
+// 需要在持有锁时调用
+func (g *Group[K, V]) add(key K) *call[V] {
+	if g.calls == nil {
+		g.calls = map[K]*call[V]{}
+	}
+	c := &call[V]{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	atomic.AddInt32(&g.inflight, 1)
+	return c
+}
+
+func (g *Group[K, V]) run(key K, c *call[V], fn func(stale func() bool) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.panicked = true
+			c.panicValue = r
+			c.err = fmt.Errorf("%w: %v", ErrPanic, r)
+		}
+
+		g.lock.Lock()
+		// 被Forget后可能已经有新的加载
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		atomic.AddInt32(&g.inflight, -1)
+		g.loads++
+		if c.err != nil {
+			g.loadErrors++
+		}
+		g.lock.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn(func() bool {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		return c.stale
+	})
+}
+
+// Forget 标记key正在执行的加载结果已过时，之后的调用开始新的加载
+func (g *Group[K, V]) Forget(key K) {
+	if atomic.LoadInt32(&g.inflight) == 0 {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if c, ok := g.calls[key]; ok {
+		c.stale = true
+		delete(g.calls, key)
+	}
+}
+
+// ForgetAll 标记所有正在执行的加载结果已过时
+func (g *Group[K, V]) ForgetAll() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for key, c := range g.calls {
+		c.stale = true
+		delete(g.calls, key)
+	}
+}
+
+// Stats 获得加载次数及失败次数
+func (g *Group[K, V]) Stats() (loads, loadErrors uint64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.loads, g.loadErrors
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import "container/list"
+
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+type arcEntry struct {
+	key   interface{}
+	value interface{}
+	// 所在队列：arcT1 arcT2 arcB1 arcB2
+	where int
+	elem  *list.Element
+}
+
+// ARC 自适应替换缓存(Adaptive Replacement Cache)
+// T1保存只访问过一次的元素，T2保存访问过多次的元素，B1、B2分别记录从T1、T2淘汰的key，
+// 根据B1、B2的命中情况动态调整T1的目标大小，能够抵抗顺序扫描对热点数据的冲刷
+type ARC struct {
+	m     map[interface{}]*arcEntry
+	lists [4]*list.List
+	// T1的目标大小
+	p int
+
+	cap int
+}
+
+func NewArcCache(capacity int) *ARC {
+	ret := &ARC{
+		m:   map[interface{}]*arcEntry{},
+		cap: capacity,
+	}
+	for i := range ret.lists {
+		ret.lists[i] = list.New()
+	}
+	return ret
+}
+
+func (m *ARC) len(where int) int {
+	return m.lists[where].Len()
+}
+
+func (m *ARC) moveTo(e *arcEntry, where int) {
+	m.lists[e.where].Remove(e.elem)
+	e.where = where
+	e.elem = m.lists[where].PushFront(e)
+	if where == arcB1 || where == arcB2 {
+		e.value = nil
+	}
+}
+
+func (m *ARC) removeLRU(where int) {
+	if back := m.lists[where].Back(); back != nil {
+		e := m.lists[where].Remove(back).(*arcEntry)
+		delete(m.m, e.key)
+	}
+}
+
+// 缓存已满时将T1或T2的最久未访问元素淘汰到对应的B1或B2
+func (m *ARC) replace(inB2 bool) {
+	if m.len(arcT1)+m.len(arcT2) < m.cap {
+		return
+	}
+	t1 := m.len(arcT1)
+	if t1 > 0 && (t1 > m.p || (inB2 && t1 == m.p)) {
+		m.moveTo(m.lists[arcT1].Back().Value.(*arcEntry), arcB1)
+	} else if back := m.lists[arcT2].Back(); back != nil {
+		m.moveTo(back.Value.(*arcEntry), arcB2)
+	} else {
+		m.moveTo(m.lists[arcT1].Back().Value.(*arcEntry), arcB1)
+	}
+}
+
+// 向Map中添加一个元素
+// Param：key 添加的对象key，value 添加的对象
+func (m *ARC) Put(key, value interface{}) {
+	if m.cap <= 0 {
+		return
+	}
+	if e, ok := m.m[key]; ok {
+		switch e.where {
+		case arcT1, arcT2:
+			e.value = value
+			m.moveTo(e, arcT2)
+			return
+		case arcB1:
+			delta := 1
+			if b1, b2 := m.len(arcB1), m.len(arcB2); b2 > b1 {
+				delta = b2 / b1
+			}
+			m.p += delta
+			if m.p > m.cap {
+				m.p = m.cap
+			}
+			m.replace(false)
+		case arcB2:
+			delta := 1
+			if b1, b2 := m.len(arcB1), m.len(arcB2); b1 > b2 {
+				delta = b1 / b2
+			}
+			m.p -= delta
+			if m.p < 0 {
+				m.p = 0
+			}
+			m.replace(true)
+		}
+		m.moveTo(e, arcT2)
+		e.value = value
+		return
+	}
+
+	t1b1 := m.len(arcT1) + m.len(arcB1)
+	total := t1b1 + m.len(arcT2) + m.len(arcB2)
+	if t1b1 >= m.cap {
+		if m.len(arcT1) < m.cap {
+			m.removeLRU(arcB1)
+			m.replace(false)
+		} else {
+			m.removeLRU(arcT1)
+		}
+	} else if total >= m.cap {
+		if total >= 2*m.cap {
+			m.removeLRU(arcB2)
+		}
+		m.replace(false)
+	}
+	e := &arcEntry{key: key, value: value, where: arcT1}
+	e.elem = m.lists[arcT1].PushFront(e)
+	m.m[key] = e
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *ARC) Get(key interface{}) (value interface{}, loaded bool) {
+	if e, ok := m.m[key]; ok && (e.where == arcT1 || e.where == arcT2) {
+		m.moveTo(e, arcT2)
+		return e.value, true
+	}
+	return nil, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *ARC) Delete(key interface{}) {
+	if e, ok := m.m[key]; ok {
+		m.lists[e.where].Remove(e.elem)
+		delete(m.m, key)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *ARC) Size() int {
+	return m.len(arcT1) + m.len(arcT2)
+}
+
+// Purge 清空缓存
+func (m *ARC) Purge() {
+	m.m = map[interface{}]*arcEntry{}
+	for _, l := range m.lists {
+		l.Init()
+	}
+	m.p = 0
+}
@@ -0,0 +1,461 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/xfali/goutils/v2/container/internal/keyhash"
+	"github.com/xfali/goutils/v2/container/internal/singleflight"
+	"github.com/xfali/goutils/v2/container/purger"
+	"sync"
+	"time"
+)
+
+// EvictReason 元素从缓存中移除的原因
+type EvictReason int
+
+const (
+	// EvictReasonCapacity 超出容量被淘汰
+	EvictReasonCapacity EvictReason = iota
+	// EvictReasonDeleted 被主动删除
+	EvictReasonDeleted
+	// EvictReasonCleared 缓存被清空
+	EvictReasonCleared
+	// EvictReasonExpired 过期被移除
+	EvictReasonExpired
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonCapacity:
+		return "capacity"
+	case EvictReasonDeleted:
+		return "deleted"
+	case EvictReasonCleared:
+		return "cleared"
+	case EvictReasonExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("EvictReason(%d)", int(r))
+	}
+}
+
+// EvictCallback 元素移除回调，在缓存锁释放后调用，可以在回调中访问缓存
+type EvictCallback[K comparable, V any] func(key K, value V, reason EvictReason)
+
+// CacheStats 缓存统计信息
+type CacheStats struct {
+	// 命中次数
+	Hits uint64
+	// 未命中次数
+	Misses uint64
+	// 超出容量被淘汰的次数
+	Evictions uint64
+	// 过期被移除的次数
+	Expirations uint64
+	// GetOrLoad调用loader的次数
+	Loads uint64
+	// GetOrLoad调用loader失败的次数
+	LoadErrors uint64
+	// 当前元素个数
+	Size int
+	// 当前元素总权重，未配置OptWeigher时与Size相等
+	Weight int64
+}
+
+// HitRatio 命中率
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type CacheOpt[K comparable, V any] func(*Cache[K, V])
+
+type cacheEntry[K comparable, V any] struct {
+	key   K
+	value V
+	// 过期时间，零值表示永不过期
+	expireTime time.Time
+	weight     int64
+}
+
+func (e *cacheEntry[K, V]) expired(now time.Time) bool {
+	return !e.expireTime.IsZero() && !e.expireTime.After(now)
+}
+
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+type cacheShard[K comparable, V any] struct {
+	lock sync.Mutex
+	// 队首为最近访问的元素
+	list *list.List
+	m    map[K]*list.Element
+	// 分片容量，以元素权重计算
+	cap    int64
+	weight int64
+
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+}
+
+// Cache 并发安全的泛型LRU缓存
+// 可以通过OptShards将缓存分为多个独立加锁的分片以降低锁竞争，每个分片独立按LRU淘汰
+type Cache[K comparable, V any] struct {
+	shards     []*cacheShard[K, V]
+	hasher     func(key K) uint64
+	onEvict    EvictCallback[K, V]
+	defaultTTL time.Duration
+	weigher    Weigher[K, V]
+
+	purgeInterval time.Duration
+	executor      purger.PurgeExecutor
+
+	loads        singleflight.Group[K, V]
+	refreshAhead time.Duration
+	negativeTTL  time.Duration
+	negatives    map[K]negativeEntry
+	negLock      sync.Mutex
+}
+
+// Weigher 计算元素的权重（如占用的字节数），返回值小于1时按1计算
+type Weigher[K comparable, V any] func(key K, value V) int64
+
+// NewCache 创建并发安全的LRU缓存
+// Param：capacity 缓存容量，默认为元素个数，配置OptWeigher后为元素权重之和，分片时平均分配到每个分片
+func NewCache[K comparable, V any](capacity int, opts ...CacheOpt[K, V]) *Cache[K, V] {
+	ret := &Cache[K, V]{
+		negatives: map[K]negativeEntry{},
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if len(ret.shards) == 0 {
+		ret.shards = make([]*cacheShard[K, V], 1)
+	}
+	if ret.hasher == nil {
+		ret.hasher = keyhash.Of[K]
+	}
+	n := len(ret.shards)
+	shardCap := (int64(capacity) + int64(n) - 1) / int64(n)
+	for i := range ret.shards {
+		ret.shards[i] = &cacheShard[K, V]{
+			list: list.New(),
+			m:    map[K]*list.Element{},
+			cap:  shardCap,
+		}
+	}
+	if ret.executor != nil {
+		if err := ret.executor.AddPurger(ret, ret.purgeInterval); err != nil {
+			panic(err)
+		}
+	}
+	return ret
+}
+
+// OptShards 配置分片数量及key的hash函数，hasher为nil时使用默认hash函数
+func OptShards[K comparable, V any](n int, hasher func(key K) uint64) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		if n < 1 {
+			n = 1
+		}
+		cache.shards = make([]*cacheShard[K, V], n)
+		cache.hasher = hasher
+	}
+}
+
+// OptWeigher 配置元素权重计算函数，缓存容量以元素权重之和计算
+// 添加元素后总权重超出容量时持续淘汰最久未访问的元素，单个元素权重超出分片容量时该元素也会被淘汰
+func OptWeigher[K comparable, V any](weigher Weigher[K, V]) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.weigher = weigher
+	}
+}
+
+// OptOnEvict 配置元素移除回调
+func OptOnEvict[K comparable, V any](callback EvictCallback[K, V]) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.onEvict = callback
+	}
+}
+
+// OptDefaultTTL 配置Put添加元素的默认过期时间，小于等于0表示永不过期（默认）
+func OptDefaultTTL[K comparable, V any](ttl time.Duration) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.defaultTTL = ttl
+	}
+}
+
+// OptAutoPurge 配置自动清理过期元素，到interval时间间隔后由executor调用缓存的Purge方法
+// executor为nil时使用全局清理执行器
+// 未配置时过期元素只在访问时被动移除
+func OptAutoPurge[K comparable, V any](interval time.Duration, executor purger.PurgeExecutor) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		if executor == nil {
+			executor = execInstance()
+		}
+		cache.purgeInterval = interval
+		cache.executor = executor
+	}
+}
+
+func (c *Cache[K, V]) weigh(key K, value V) int64 {
+	if c.weigher == nil {
+		return 1
+	}
+	if w := c.weigher(key, value); w > 1 {
+		return w
+	}
+	return 1
+}
+
+func (c *Cache[K, V]) shard(key K) *cacheShard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[c.hasher(key)%uint64(len(c.shards))]
+}
+
+func (c *Cache[K, V]) notify(list []evicted[K, V]) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, e := range list {
+		c.onEvict(e.key, e.value, e.reason)
+	}
+}
+
+// 向缓存中添加一个元素，过期时间为默认过期时间，超出容量时淘汰最久未访问的元素
+// Param：key 添加的对象key，value 添加的对象
+func (c *Cache[K, V]) Put(key K, value V) {
+	c.PutWithTTL(key, value, c.defaultTTL)
+}
+
+// PutWithTTL 向缓存中添加一个元素并指定过期时间，超出容量时淘汰最久未访问的元素
+// Param：key 添加的对象key，value 添加的对象，ttl 过期时间，小于等于0表示永不过期
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) {
+	c.put(key, value, ttl, nil)
+}
+
+// 添加元素，stale不为nil时为GetOrLoad加载的结果，加载期间key被修改或删除时放弃添加
+func (c *Cache[K, V]) put(key K, value V, ttl time.Duration, stale func() bool) {
+	var expireTime time.Time
+	if ttl > 0 {
+		expireTime = time.Now().Add(ttl)
+	}
+	weight := c.weigh(key, value)
+	s := c.shard(key)
+	s.lock.Lock()
+	if stale == nil {
+		c.loads.Forget(key)
+	} else if stale() {
+		s.lock.Unlock()
+		return
+	}
+	var out []evicted[K, V]
+	if e, ok := s.m[key]; ok {
+		entry := e.Value.(*cacheEntry[K, V])
+		entry.value = value
+		entry.expireTime = expireTime
+		s.weight += weight - entry.weight
+		entry.weight = weight
+		s.list.MoveToFront(e)
+	} else {
+		s.m[key] = s.list.PushFront(&cacheEntry[K, V]{key: key, value: value, expireTime: expireTime, weight: weight})
+		s.weight += weight
+	}
+	for s.weight > s.cap && s.list.Len() > 0 {
+		out = append(out, s.removeElement(s.list.Back(), EvictReasonCapacity))
+		s.evictions++
+	}
+	s.lock.Unlock()
+	// 在forget之后清除，正在执行的加载不会再缓存错误
+	c.clearNegative(key)
+	c.notify(out)
+}
+
+// 获取key对应的元素，命中时将元素标记为最近访问，已过期的元素被移除并视为未命中
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (c *Cache[K, V]) Get(key K) (value V, loaded bool) {
+	value, _, loaded = c.get(key, time.Now())
+	return
+}
+
+func (c *Cache[K, V]) get(key K, now time.Time) (value V, expireTime time.Time, loaded bool) {
+	s := c.shard(key)
+	s.lock.Lock()
+	var out []evicted[K, V]
+	if e, ok := s.m[key]; ok {
+		entry := e.Value.(*cacheEntry[K, V])
+		if !entry.expired(now) {
+			s.list.MoveToFront(e)
+			s.hits++
+			value, expireTime = entry.value, entry.expireTime
+			s.lock.Unlock()
+			return value, expireTime, true
+		}
+		out = append(out, s.removeElement(e, EvictReasonExpired))
+		s.expirations++
+	}
+	s.misses++
+	s.lock.Unlock()
+	c.notify(out)
+	return value, expireTime, false
+}
+
+// Peek 获取key对应的元素，不改变元素的访问顺序也不计入统计
+func (c *Cache[K, V]) Peek(key K) (value V, loaded bool) {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.m[key]; ok {
+		entry := e.Value.(*cacheEntry[K, V])
+		if !entry.expired(time.Now()) {
+			return entry.value, true
+		}
+	}
+	return value, false
+}
+
+// TTL 获得key的剩余过期时间
+// Return：key不存在或已过期返回-2，永不过期返回-1
+func (c *Cache[K, V]) TTL(key K) time.Duration {
+	s := c.shard(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.m[key]; ok {
+		entry := e.Value.(*cacheEntry[K, V])
+		if entry.expireTime.IsZero() {
+			return -1
+		}
+		if ttl := time.Until(entry.expireTime); ttl > 0 {
+			return ttl
+		}
+	}
+	return -2
+}
+
+// 删除key对应的元素
+// Param：key
+func (c *Cache[K, V]) Delete(key K) {
+	s := c.shard(key)
+	s.lock.Lock()
+	c.loads.Forget(key)
+	var out []evicted[K, V]
+	if e, ok := s.m[key]; ok {
+		out = append(out, s.removeElement(e, EvictReasonDeleted))
+	}
+	s.lock.Unlock()
+	c.clearNegative(key)
+	c.notify(out)
+}
+
+// Purge 移除所有已过期的元素，由清理执行器定时调用，也可以手动调用
+func (c *Cache[K, V]) Purge() {
+	now := time.Now()
+	for _, s := range c.shards {
+		s.lock.Lock()
+		var out []evicted[K, V]
+		for e := s.list.Front(); e != nil; {
+			next := e.Next()
+			if e.Value.(*cacheEntry[K, V]).expired(now) {
+				out = append(out, s.removeElement(e, EvictReasonExpired))
+				s.expirations++
+			}
+			e = next
+		}
+		s.lock.Unlock()
+		c.notify(out)
+	}
+	c.purgeNegative(now)
+}
+
+// 获得缓存元素个数（包含已过期但尚未移除的元素）
+func (c *Cache[K, V]) Size() int {
+	size := 0
+	for _, s := range c.shards {
+		s.lock.Lock()
+		size += len(s.m)
+		s.lock.Unlock()
+	}
+	return size
+}
+
+// Clear 清空缓存
+func (c *Cache[K, V]) Clear() {
+	c.loads.ForgetAll()
+	for _, s := range c.shards {
+		s.lock.Lock()
+		out := make([]evicted[K, V], 0, len(s.m))
+		for e := s.list.Back(); e != nil; e = s.list.Back() {
+			out = append(out, s.removeElement(e, EvictReasonCleared))
+		}
+		s.lock.Unlock()
+		c.notify(out)
+	}
+	c.negLock.Lock()
+	c.negatives = map[K]negativeEntry{}
+	c.negLock.Unlock()
+}
+
+// Close 关闭缓存，配置了OptAutoPurge并且清理执行器实现了purger.PurgerRemover时从清理执行器中移除，缓存中的元素保持不变
+func (c *Cache[K, V]) Close() error {
+	if c.executor != nil {
+		// 重复关闭时已经移除，忽略错误
+		if r, ok := c.executor.(purger.PurgerRemover); ok {
+			r.RemovePurger(c)
+		}
+	}
+	return nil
+}
+
+// Stats 获得缓存统计信息
+func (c *Cache[K, V]) Stats() CacheStats {
+	ret := CacheStats{}
+	for _, s := range c.shards {
+		s.lock.Lock()
+		ret.Hits += s.hits
+		ret.Misses += s.misses
+		ret.Evictions += s.evictions
+		ret.Expirations += s.expirations
+		ret.Size += len(s.m)
+		ret.Weight += s.weight
+		s.lock.Unlock()
+	}
+	ret.Loads, ret.LoadErrors = c.loads.Stats()
+	return ret
+}
+
+func (s *cacheShard[K, V]) removeElement(e *list.Element, reason EvictReason) evicted[K, V] {
+	entry := s.list.Remove(e).(*cacheEntry[K, V])
+	delete(s.m, entry.key)
+	s.weight -= entry.weight
+	return evicted[K, V]{key: entry.key, value: entry.value, reason: reason}
+}
+
+var (
+	gExecutor purger.PurgeExecutor
+	initOnce  sync.Once
+)
+
+func execInstance() purger.PurgeExecutor {
+	initOnce.Do(func() {
+		gExecutor = purger.New()
+	})
+	return gExecutor
+}
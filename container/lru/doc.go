@@ -0,0 +1,15 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+// Package lru 提供LRU及其他淘汰策略的缓存
+//
+// Cache是并发安全的泛型LRU缓存，支持分片、过期时间、权重容量、GetOrLoad加载，
+// 并可以通过OptAutoPurge注册到purger.PurgeExecutor由后台定时清理过期元素。
+//
+// SimpleLru（NewLruCache）与LRUK（NewLruKCache）不是并发安全的，并且Purge方法用于释放缓存。
+// 通过NewLruCacheWithOpts、NewLruKCacheWithOpts配置过期时间（OptLruDefaultTTL）与权重（OptLruWeigher），
+// 过期元素在Get时被动移除，也可以由使用者在访问缓存的协程中调用RemoveExpired主动清理。
+// 需要并发访问时使用NewSyncLru加锁包装，并可以通过OptSyncLruAutoPurge注册到PurgeExecutor由后台定时调用RemoveExpired。
+package lru
@@ -0,0 +1,119 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import "container/list"
+
+// 访问频率相同的元素集合
+type lfuBucket struct {
+	freq int
+	// 队首为最近访问的元素
+	items *list.List
+}
+
+type lfuEntry struct {
+	key   interface{}
+	value interface{}
+	// 所在频率集合在LFU.freqs中的节点
+	bucket *list.Element
+	elem   *list.Element
+}
+
+// LFU 淘汰访问频率最低的元素，频率相同时淘汰最久未访问的元素，所有操作O(1)
+type LFU struct {
+	m map[interface{}]*lfuEntry
+	// 按访问频率从小到大排列的频率集合
+	freqs *list.List
+
+	cap int
+}
+
+func NewLfuCache(capacity int) *LFU {
+	return &LFU{
+		m:     map[interface{}]*lfuEntry{},
+		freqs: list.New(),
+		cap:   capacity,
+	}
+}
+
+func (m *LFU) increment(e *lfuEntry) {
+	cur := e.bucket.Value.(*lfuBucket)
+	next := e.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != cur.freq+1 {
+		next = m.freqs.InsertAfter(&lfuBucket{freq: cur.freq + 1, items: list.New()}, e.bucket)
+	}
+	cur.items.Remove(e.elem)
+	e.elem = next.Value.(*lfuBucket).items.PushFront(e)
+	if cur.items.Len() == 0 {
+		m.freqs.Remove(e.bucket)
+	}
+	e.bucket = next
+}
+
+func (m *LFU) remove(e *lfuEntry) {
+	b := e.bucket.Value.(*lfuBucket)
+	b.items.Remove(e.elem)
+	if b.items.Len() == 0 {
+		m.freqs.Remove(e.bucket)
+	}
+	delete(m.m, e.key)
+}
+
+// 向Map中添加一个元素
+// Param：key 添加的对象key，value 添加的对象
+func (m *LFU) Put(key, value interface{}) {
+	if e, ok := m.m[key]; ok {
+		e.value = value
+		m.increment(e)
+		return
+	}
+	if m.cap <= 0 {
+		return
+	}
+	if len(m.m) >= m.cap {
+		b := m.freqs.Front().Value.(*lfuBucket)
+		m.remove(b.items.Back().Value.(*lfuEntry))
+	}
+
+	front := m.freqs.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = m.freqs.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+	e := &lfuEntry{key: key, value: value, bucket: front}
+	e.elem = front.Value.(*lfuBucket).items.PushFront(e)
+	m.m[key] = e
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *LFU) Get(key interface{}) (value interface{}, loaded bool) {
+	if e, ok := m.m[key]; ok {
+		m.increment(e)
+		return e.value, true
+	}
+	return nil, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *LFU) Delete(key interface{}) {
+	if e, ok := m.m[key]; ok {
+		m.remove(e)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *LFU) Size() int {
+	return len(m.m)
+}
+
+// Purge 清空缓存
+func (m *LFU) Purge() {
+	m.m = map[interface{}]*lfuEntry{}
+	m.freqs.Init()
+}
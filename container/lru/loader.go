@@ -0,0 +1,118 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import (
+	"github.com/xfali/goutils/v2/container/internal/singleflight"
+	"time"
+)
+
+// Loader 缓存未命中时加载key对应的元素
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// ErrLoaderPanic loader发生panic，等待同一次加载的其他调用者返回该错误（包装了panic的值）
+var ErrLoaderPanic = singleflight.ErrPanic
+
+type negativeEntry struct {
+	err        error
+	expireTime time.Time
+}
+
+// GetOrLoad 获取key对应的元素，未命中时调用loader加载并以默认过期时间放入缓存
+// 同一个key的并发未命中只会调用一次loader，其他调用者等待并共享加载结果。
+// 配置OptNegativeTTL后加载失败的错误会被缓存，有效期内直接返回该错误而不再调用loader；
+// 配置OptRefreshAhead后命中的元素剩余过期时间小于配置值时会异步重新加载，本次调用仍返回当前值。
+// 加载期间key被Put、Delete或缓存被Clear时，加载结果只返回给调用者而不放入缓存。
+// loader发生panic时执行loader的调用者重新panic，其他等待的调用者返回ErrLoaderPanic。
+func (c *Cache[K, V]) GetOrLoad(key K, loader Loader[K, V]) (V, error) {
+	now := time.Now()
+	if v, expireTime, ok := c.get(key, now); ok {
+		if c.refreshAhead > 0 && !expireTime.IsZero() && expireTime.Sub(now) <= c.refreshAhead {
+			c.loads.DoAsync(key, func(stale func() bool) (V, error) {
+				return c.load(key, loader, stale)
+			})
+		}
+		return v, nil
+	}
+	if err := c.negative(key, now); err != nil {
+		var v V
+		return v, err
+	}
+	return c.loads.Do(key, func(stale func() bool) (V, error) {
+		return c.load(key, loader, stale)
+	})
+}
+
+func (c *Cache[K, V]) load(key K, loader Loader[K, V], stale func() bool) (V, error) {
+	v, err := loader(key)
+	if err != nil {
+		if c.negativeTTL > 0 {
+			c.negLock.Lock()
+			if !stale() {
+				c.negatives[key] = negativeEntry{err: err, expireTime: time.Now().Add(c.negativeTTL)}
+			}
+			c.negLock.Unlock()
+		}
+		return v, err
+	}
+	c.put(key, v, c.defaultTTL, stale)
+	return v, nil
+}
+
+// 获得缓存的加载错误
+func (c *Cache[K, V]) negative(key K, now time.Time) error {
+	if c.negativeTTL <= 0 {
+		return nil
+	}
+	c.negLock.Lock()
+	defer c.negLock.Unlock()
+
+	if e, ok := c.negatives[key]; ok {
+		if e.expireTime.After(now) {
+			return e.err
+		}
+		delete(c.negatives, key)
+	}
+	return nil
+}
+
+func (c *Cache[K, V]) clearNegative(key K) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.negLock.Lock()
+	delete(c.negatives, key)
+	c.negLock.Unlock()
+}
+
+func (c *Cache[K, V]) purgeNegative(now time.Time) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	c.negLock.Lock()
+	defer c.negLock.Unlock()
+
+	for k, e := range c.negatives {
+		if !e.expireTime.After(now) {
+			delete(c.negatives, k)
+		}
+	}
+}
+
+// OptNegativeTTL 配置GetOrLoad加载失败的错误缓存时间，小于等于0表示不缓存错误（默认）
+func OptNegativeTTL[K comparable, V any](ttl time.Duration) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.negativeTTL = ttl
+	}
+}
+
+// OptRefreshAhead 配置GetOrLoad提前刷新时间，命中元素的剩余过期时间小于等于d时异步重新加载
+// 只对有过期时间的元素生效
+func OptRefreshAhead[K comparable, V any](d time.Duration) CacheOpt[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.refreshAhead = d
+	}
+}
@@ -7,6 +7,7 @@ package lru
 
 import (
 	"github.com/xfali/goutils/v2/container/xmap"
+	"time"
 )
 
 type LRU interface {
@@ -15,19 +16,92 @@ type LRU interface {
 	Purge()
 }
 
+// LruOpt SimpleLru与LRUK的配置
+type LruOpt func(*lruConfig)
+
+type lruConfig struct {
+	defaultTTL time.Duration
+	weigher    LruWeigher
+}
+
+// LruWeigher 计算SimpleLru、LRUK元素的权重（如占用的字节数），返回值小于1时按1计算
+type LruWeigher func(key, value interface{}) int64
+
+// OptLruDefaultTTL 配置SimpleLru、LRUK的Put添加元素的默认过期时间，小于等于0表示永不过期（默认）
+func OptLruDefaultTTL(ttl time.Duration) LruOpt {
+	return func(conf *lruConfig) {
+		conf.defaultTTL = ttl
+	}
+}
+
+// OptLruWeigher 配置SimpleLru、LRUK的元素权重计算函数，容量以元素权重之和计算
+// 添加元素后总权重超出容量时持续淘汰最久未访问的元素，元素的值在缓存中时不能修改（权重不能改变）
+func OptLruWeigher(weigher LruWeigher) LruOpt {
+	return func(conf *lruConfig) {
+		conf.weigher = weigher
+	}
+}
+
+// 创建队列，entry获得队列元素的key与value
+func (conf lruConfig) newQueue(capacity int, entry func(v interface{}) (key, value interface{})) *LruQueue {
+	if conf.weigher == nil {
+		return NewLruQueue(capacity)
+	}
+	return NewWeightedLruQueue(capacity, func(v interface{}) int64 {
+		return conf.weigher(entry(v))
+	})
+}
+
+func newLruConfig(opts []LruOpt) lruConfig {
+	conf := lruConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return conf
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl > 0 {
+		return time.Now().Add(ttl)
+	}
+	return time.Time{}
+}
+
+func isExpired(expireTime, now time.Time) bool {
+	return !expireTime.IsZero() && !expireTime.After(now)
+}
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+	// 过期时间，零值表示永不过期
+	expireTime time.Time
+}
+
 type SimpleLru struct {
 	m     map[interface{}]*QueueElem
 	queue *LruQueue
 
-	cap int
+	cap        int
+	defaultTTL time.Duration
 }
 
 func NewLruCache(capacity int) *SimpleLru {
+	return NewLruCacheWithOpts(capacity)
+}
+
+// NewLruCacheWithOpts 创建LRU缓存并指定配置，非并发安全
+func NewLruCacheWithOpts(capacity int, opts ...LruOpt) *SimpleLru {
+	conf := newLruConfig(opts)
 	ret := &SimpleLru{
-		m:   map[interface{}]*QueueElem{},
-		cap: capacity,
+		m:          map[interface{}]*QueueElem{},
+		cap:        capacity,
+		defaultTTL: conf.defaultTTL,
 	}
-	ret.queue = NewLruQueue(capacity)
+	ret.queue = conf.newQueue(capacity, func(v interface{}) (key, value interface{}) {
+		e := v.(*lruEntry)
+		return e.key, e.value
+	})
 	ret.queue.AddListener(ret)
 	return ret
 }
@@ -39,8 +113,7 @@ func (m *SimpleLru) PostInsert(v interface{}) {
 }
 
 func (m *SimpleLru) PostDelete(v interface{}) {
-	key := v.([2]interface{})[0]
-	delete(m.m, key)
+	delete(m.m, v.(*lruEntry).key)
 }
 
 func (m *SimpleLru) hit(key interface{}, hit bool) {
@@ -53,15 +126,21 @@ func (m *SimpleLru) Purge() {
 	m.queue = nil
 }
 
-// 向Map中添加一个元素
+// 向Map中添加一个元素，过期时间为默认过期时间
 // Param：key 添加的对象key，value 添加的对象
 func (m *SimpleLru) Put(key, value interface{}) {
+	m.PutWithTTL(key, value, m.defaultTTL)
+}
+
+// PutWithTTL 向Map中添加一个元素并指定过期时间
+// Param：key 添加的对象key，value 添加的对象，ttl 过期时间，小于等于0表示永不过期
+func (m *SimpleLru) PutWithTTL(key, value interface{}, ttl time.Duration) {
+	entry := &lruEntry{key: key, value: value, expireTime: expireAt(ttl)}
 	e, ok := m.m[key]
 	if ok {
-		e.Value = [2]interface{}{key, value}
-		m.queue.Touch(e)
+		m.queue.Replace(e, entry)
 	} else {
-		elem := m.queue.Insert([2]interface{}{key, value})
+		elem := m.queue.Insert(entry)
 		m.m[key] = elem
 	}
 }
@@ -71,11 +150,15 @@ func (m *SimpleLru) Put(key, value interface{}) {
 // Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
 func (m *SimpleLru) Get(key interface{}) (value interface{}, loaded bool) {
 	v, ok := m.m[key]
+	if ok && isExpired(v.Value.(*lruEntry).expireTime, time.Now()) {
+		m.queue.Delete(v)
+		ok = false
+	}
 	if ok {
 		m.queue.Touch(v)
 		// 命中
 		m.hit(key, true)
-		return v.Value.([2]interface{})[1], true
+		return v.Value.(*lruEntry).value, true
 	} else {
 		// 未命中
 		m.hit(key, false)
@@ -92,8 +175,28 @@ func (m *SimpleLru) Delete(key interface{}) {
 	}
 }
 
-// 获得Map长度
+// RemoveExpired 移除所有已过期的元素，返回移除的个数
+func (m *SimpleLru) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for e := m.queue.list.Back(); e != nil; {
+		prev := e.Prev()
+		if isExpired(e.Value.(*lruEntry).expireTime, now) {
+			m.queue.Delete((*QueueElem)(e))
+			n++
+		}
+		e = prev
+	}
+	return n
+}
+
+// 获得Map长度（包含已过期但尚未移除的元素）
 // Return： 链表长度
 func (m *SimpleLru) Size() int {
 	return len(m.m)
 }
+
+// Weight 获得当前元素总权重，未配置OptLruWeigher时与Size相等
+func (m *SimpleLru) Weight() int64 {
+	return m.queue.Weight()
+}
@@ -5,6 +5,8 @@
 
 package lru
 
+import "time"
+
 // history node
 type hnode struct {
 	hits int
@@ -12,6 +14,8 @@ type hnode struct {
 	k interface{}
 	// value
 	v interface{}
+	// 过期时间，零值表示永不过期
+	expireTime time.Time
 
 	he *QueueElem
 	ce *QueueElem
@@ -21,6 +25,7 @@ func (n *hnode) clear() {
 	n.hits = 0
 	n.k = nil
 	n.v = nil
+	n.expireTime = time.Time{}
 
 	n.he = nil
 	n.ce = nil
@@ -33,17 +38,29 @@ type LRUK struct {
 	// cache queue
 	cQueue *LruQueue
 
-	purgeFunc func()
+	purgeFunc  func()
+	defaultTTL time.Duration
 }
 
 func NewLruKCache(k, historyCapacity, cacheCapacity int) *LRUK {
+	return NewLruKCacheWithOpts(k, historyCapacity, cacheCapacity)
+}
+
+// NewLruKCacheWithOpts 创建LRU-K缓存并指定配置，非并发安全
+func NewLruKCacheWithOpts(k, historyCapacity, cacheCapacity int, opts ...LruOpt) *LRUK {
+	conf := newLruConfig(opts)
 	ret := &LRUK{
-		m: map[interface{}]*hnode{},
+		m:          map[interface{}]*hnode{},
+		defaultTTL: conf.defaultTTL,
+	}
+	entry := func(v interface{}) (key, value interface{}) {
+		n := v.(*hnode)
+		return n.k, n.v
 	}
-	ret.cQueue = NewLruQueue(cacheCapacity)
+	ret.cQueue = conf.newQueue(cacheCapacity, entry)
 	cl := &cacheListener{lru: ret}
 	ret.cQueue.AddListener(cl)
-	ret.hQueue = NewLruQueue(historyCapacity)
+	ret.hQueue = conf.newQueue(historyCapacity, entry)
 	hl := &historyListener{lru: ret, history: ret.hQueue, cache: ret.cQueue, k: k}
 	ret.hQueue.AddListener(hl)
 	ret.purgeFunc = func() {
@@ -71,9 +88,15 @@ func (m *LRUK) Purge() {
 	m.purgeFunc()
 }
 
-// 向Map中添加一个元素
+// 向Map中添加一个元素，过期时间为默认过期时间
 // Param：key 添加的对象key，value 添加的对象
 func (m *LRUK) Put(key, value interface{}) {
+	m.PutWithTTL(key, value, m.defaultTTL)
+}
+
+// PutWithTTL 向Map中添加一个元素并指定过期时间
+// Param：key 添加的对象key，value 添加的对象，ttl 过期时间，小于等于0表示永不过期
+func (m *LRUK) PutWithTTL(key, value interface{}, ttl time.Duration) {
 	e, ok := m.m[key]
 	if ok {
 		n := e
@@ -85,7 +108,7 @@ func (m *LRUK) Put(key, value interface{}) {
 		}
 		n.clear()
 	}
-	e = &hnode{k: key, v: value}
+	e = &hnode{k: key, v: value, expireTime: expireAt(ttl)}
 	elem := m.hQueue.Insert(e)
 	e.he = elem
 	m.m[key] = e
@@ -100,6 +123,11 @@ func (m *LRUK) Get(key interface{}) (value interface{}, loaded bool) {
 		if m.checkAndDelete(v) {
 			return nil, false
 		}
+		if isExpired(v.expireTime, time.Now()) {
+			m.delete(key, v)
+			m.hit(key, false)
+			return nil, false
+		}
 		if v.he != nil {
 			m.hQueue.Touch(v.he)
 		} else {
@@ -141,12 +169,30 @@ func (m *LRUK) Delete(key interface{}) {
 	}
 }
 
-// 获得Map长度
+// RemoveExpired 移除所有已过期的元素，返回移除的个数
+func (m *LRUK) RemoveExpired() int {
+	now := time.Now()
+	n := 0
+	for k, v := range m.m {
+		if isExpired(v.expireTime, now) {
+			m.delete(k, v)
+			n++
+		}
+	}
+	return n
+}
+
+// 获得Map长度（包含已过期但尚未移除的元素）
 // Return： 链表长度
 func (m *LRUK) Size() int {
 	return len(m.m)
 }
 
+// Weight 获得历史队列与缓存队列的元素总权重，未配置OptLruWeigher时为队列的元素个数
+func (m *LRUK) Weight() (history, cache int64) {
+	return m.hQueue.Weight(), m.cQueue.Weight()
+}
+
 type historyListener struct {
 	lru     *LRUK
 	history *LruQueue
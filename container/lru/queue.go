@@ -35,6 +35,10 @@ type LruQueue struct {
 	list      *list.List
 	cap       int
 	listeners []QueueListener
+
+	// 配置后cap为元素权重之和，否则为元素个数
+	weigher func(v interface{}) int64
+	weight  int64
 }
 
 func NewLruElement(v interface{}) *QueueElem {
@@ -51,6 +55,30 @@ func NewLruQueue(cap int) *LruQueue {
 	}
 }
 
+// NewWeightedLruQueue 创建以元素权重之和计算容量的队列
+// weigher计算元素的权重，返回值小于1时按1计算；元素在队列中时权重不能改变，需要修改值时使用Replace
+// 插入元素后总权重超出容量时持续淘汰队尾元素，单个元素权重超出容量时队列中只保留该元素
+func NewWeightedLruQueue(cap int, weigher func(v interface{}) int64) *LruQueue {
+	q := NewLruQueue(cap)
+	q.weigher = weigher
+	return q
+}
+
+func (q *LruQueue) weigh(v interface{}) int64 {
+	if q.weigher == nil {
+		return 1
+	}
+	if w := q.weigher(v); w > 1 {
+		return w
+	}
+	return 1
+}
+
+// Weight 获得队列中元素的总权重，未配置weigher时为元素个数
+func (q *LruQueue) Weight() int64 {
+	return q.weight
+}
+
 func (q *LruQueue) AddListener(listener QueueListener) {
 	q.listeners = append(q.listeners, listener)
 }
@@ -69,12 +97,15 @@ func (q *LruQueue) Move(other *LruQueue, elem *QueueElem, notify bool) *QueueEle
 		return nil
 	}
 	v := q.list.Remove((*list.Element)(elem))
+	w := q.weigh(v)
+	q.weight -= w
 	if notify {
 		for _, l := range q.listeners {
 			l.PostDelete(elem.Value)
 		}
 	}
 
+	other.weight += w
 	elem = (*QueueElem)(other.list.PushFront(v))
 	if notify {
 		for _, l := range q.listeners {
@@ -85,7 +116,12 @@ func (q *LruQueue) Move(other *LruQueue, elem *QueueElem, notify bool) *QueueEle
 }
 
 func (q *LruQueue) Insert(v interface{}) *QueueElem {
-	if q.list.Len() == q.cap {
+	w := q.weigh(v)
+	if q.weigher != nil {
+		for q.list.Len() > 0 && q.weight+w > int64(q.cap) {
+			q.Delete((*QueueElem)(q.list.Back()))
+		}
+	} else if q.list.Len() == q.cap {
 		e := q.list.Back()
 		if e != nil {
 			q.Delete((*QueueElem)(e))
@@ -93,14 +129,26 @@ func (q *LruQueue) Insert(v interface{}) *QueueElem {
 	}
 
 	e := q.list.PushFront(v)
+	q.weight += w
 	for _, l := range q.listeners {
 		l.PostInsert(v)
 	}
 	return (*QueueElem)(e)
 }
 
+// Replace 替换元素的值并移动到队列头部，总权重超出容量时淘汰队尾元素（不会淘汰该元素）
+func (q *LruQueue) Replace(elem *QueueElem, v interface{}) {
+	q.weight += q.weigh(v) - q.weigh(elem.Value)
+	elem.Value = v
+	q.Touch(elem)
+	for q.weigher != nil && q.weight > int64(q.cap) && q.list.Len() > 1 {
+		q.Delete((*QueueElem)(q.list.Back()))
+	}
+}
+
 func (q *LruQueue) Delete(elem *QueueElem) {
 	v := q.list.Remove((*list.Element)(elem))
+	q.weight -= q.weigh(v)
 	for _, l := range q.listeners {
 		l.PostDelete(v)
 	}
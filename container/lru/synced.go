@@ -0,0 +1,163 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import (
+	"github.com/xfali/goutils/v2/container/purger"
+	"sync"
+	"time"
+)
+
+// ExpirableLRU 支持过期时间的LRU，SimpleLru与LRUK实现了该接口
+type ExpirableLRU interface {
+	LRU
+
+	// PutWithTTL 添加元素并指定过期时间，小于等于0表示永不过期
+	PutWithTTL(key, value interface{}, ttl time.Duration)
+
+	// RemoveExpired 移除所有已过期的元素，返回移除的个数
+	RemoveExpired() int
+}
+
+var (
+	_ ExpirableLRU = (*SimpleLru)(nil)
+	_ ExpirableLRU = (*LRUK)(nil)
+)
+
+// SyncLru 为SimpleLru、LRUK加锁，所有方法并发安全
+// 通过OptSyncLruAutoPurge注册到purger.PurgeExecutor后由后台定时调用RemoveExpired清理过期元素
+type SyncLru struct {
+	lock     sync.Mutex
+	lru      ExpirableLRU
+	released bool
+
+	purgeInterval time.Duration
+	executor      purger.PurgeExecutor
+	purger        *expiredPurger
+}
+
+type SyncLruOpt func(*SyncLru)
+
+// 注册到PurgeExecutor的清理器，LRU的Purge方法用于释放缓存，因此不能直接注册
+type expiredPurger struct {
+	lru *SyncLru
+}
+
+func (p *expiredPurger) Purge() {
+	p.lru.RemoveExpired()
+}
+
+// NewSyncLru 创建并发安全的LRU，之后只能通过SyncLru访问lru
+func NewSyncLru(lru ExpirableLRU, opts ...SyncLruOpt) *SyncLru {
+	ret := &SyncLru{
+		lru: lru,
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if ret.executor != nil {
+		ret.purger = &expiredPurger{lru: ret}
+		if err := ret.executor.AddPurger(ret.purger, ret.purgeInterval); err != nil {
+			panic(err)
+		}
+	}
+	return ret
+}
+
+// OptSyncLruAutoPurge 配置自动清理过期元素，到interval时间间隔后由executor调用RemoveExpired
+// executor为nil时使用全局清理执行器
+func OptSyncLruAutoPurge(interval time.Duration, executor purger.PurgeExecutor) SyncLruOpt {
+	return func(l *SyncLru) {
+		if executor == nil {
+			executor = execInstance()
+		}
+		l.purgeInterval = interval
+		l.executor = executor
+	}
+}
+
+// 向Map中添加一个元素，过期时间为默认过期时间
+func (l *SyncLru) Put(key, value interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lru.Put(key, value)
+}
+
+// PutWithTTL 向Map中添加一个元素并指定过期时间，小于等于0表示永不过期
+func (l *SyncLru) PutWithTTL(key, value interface{}, ttl time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lru.PutWithTTL(key, value, ttl)
+}
+
+// 获取key对应的元素
+func (l *SyncLru) Get(key interface{}) (value interface{}, loaded bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lru.Get(key)
+}
+
+// 删除key对应的元素
+func (l *SyncLru) Delete(key interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lru.Delete(key)
+}
+
+// 获得Map长度（包含已过期但尚未移除的元素）
+func (l *SyncLru) Size() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.lru.Size()
+}
+
+// RemoveExpired 移除所有已过期的元素，返回移除的个数，释放后返回0
+func (l *SyncLru) RemoveExpired() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.released {
+		return 0
+	}
+	return l.lru.RemoveExpired()
+}
+
+// Do 持有锁调用f访问被包装的LRU（如获得Weight），f中不能调用SyncLru的方法
+func (l *SyncLru) Do(f func(lru ExpirableLRU)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	f(l.lru)
+}
+
+// Purge 关闭并释放缓存，释放后不能再使用
+func (l *SyncLru) Purge() {
+	l.Close()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.released {
+		l.released = true
+		l.lru.Purge()
+	}
+}
+
+// Close 配置了OptSyncLruAutoPurge并且清理执行器实现了purger.PurgerRemover时从清理执行器中移除，缓存中的元素保持不变
+func (l *SyncLru) Close() error {
+	if l.executor != nil {
+		// 重复关闭时已经移除，忽略错误
+		if r, ok := l.executor.(purger.PurgerRemover); ok {
+			r.RemovePurger(l.purger)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import (
+	"container/list"
+	"github.com/xfali/goutils/v2/container/internal/keyhash"
+)
+
+const (
+	sketchDepth = 4
+	// 计数器最大值，与4bit计数器一致
+	sketchMaxCount = 15
+)
+
+var sketchSeeds = [sketchDepth]uint64{
+	0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325,
+}
+
+// count-min sketch，估计key的访问频率，计数总数达到resetAt时所有计数减半以淘汰历史频率
+type cmSketch struct {
+	rows    [sketchDepth][]uint8
+	mask    uint64
+	added   int
+	resetAt int
+}
+
+func newCmSketch(capacity int) *cmSketch {
+	width := 16
+	for width < capacity {
+		width <<= 1
+	}
+	ret := &cmSketch{
+		mask:    uint64(width - 1),
+		resetAt: 10 * capacity,
+	}
+	if ret.resetAt < 10*width/4 {
+		ret.resetAt = 10 * width / 4
+	}
+	for i := range ret.rows {
+		ret.rows[i] = make([]uint8, width)
+	}
+	return ret
+}
+
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := keyhash.Mix64(h^sketchSeeds[i]) & s.mask
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.added++
+	if s.added >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(h uint64) uint8 {
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if v := s.rows[i][keyhash.Mix64(h^sketchSeeds[i])&s.mask]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.added /= 2
+}
+
+const (
+	tinyLfuWindow = iota
+	tinyLfuProbation
+	tinyLfuProtected
+)
+
+type tinyLfuEntry struct {
+	key   interface{}
+	value interface{}
+	hash  uint64
+	// 所在队列：tinyLfuWindow tinyLfuProbation tinyLfuProtected
+	where int
+	elem  *list.Element
+}
+
+// TinyLFU W-TinyLFU缓存
+// 新元素先进入容量为1%的LRU窗口，从窗口淘汰的元素只有估计访问频率高于主缓存待淘汰元素时才被接纳，
+// 主缓存为分段LRU（20%试用段，80%保护段），兼顾突发访问与长期热点，能够抵抗扫描冲刷
+type TinyLFU struct {
+	m      map[interface{}]*tinyLfuEntry
+	lists  [3]*list.List
+	sketch *cmSketch
+
+	windowCap    int
+	mainCap      int
+	protectedCap int
+}
+
+func NewTinyLfuCache(capacity int) *TinyLFU {
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap := capacity - windowCap
+	if mainCap < 0 {
+		mainCap = 0
+	}
+	ret := &TinyLFU{
+		m:            map[interface{}]*tinyLfuEntry{},
+		sketch:       newCmSketch(capacity),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+	}
+	for i := range ret.lists {
+		ret.lists[i] = list.New()
+	}
+	return ret
+}
+
+func (m *TinyLFU) push(e *tinyLfuEntry, where int) {
+	e.where = where
+	e.elem = m.lists[where].PushFront(e)
+}
+
+func (m *TinyLFU) remove(e *tinyLfuEntry) {
+	m.lists[e.where].Remove(e.elem)
+	delete(m.m, e.key)
+}
+
+func (m *TinyLFU) touch(e *tinyLfuEntry) {
+	switch e.where {
+	case tinyLfuWindow, tinyLfuProtected:
+		m.lists[e.where].MoveToFront(e.elem)
+	case tinyLfuProbation:
+		// 试用段中再次访问的元素晋升到保护段，保护段超出容量时将最久未访问的元素降级到试用段
+		m.lists[tinyLfuProbation].Remove(e.elem)
+		m.push(e, tinyLfuProtected)
+		if m.lists[tinyLfuProtected].Len() > m.protectedCap {
+			back := m.lists[tinyLfuProtected].Remove(m.lists[tinyLfuProtected].Back()).(*tinyLfuEntry)
+			m.push(back, tinyLfuProbation)
+		}
+	}
+}
+
+// 窗口超出容量时将最久未访问的元素作为候选，与主缓存的待淘汰元素比较频率决定淘汰哪一个
+func (m *TinyLFU) admit() {
+	if m.lists[tinyLfuWindow].Len() <= m.windowCap {
+		return
+	}
+	candidate := m.lists[tinyLfuWindow].Remove(m.lists[tinyLfuWindow].Back()).(*tinyLfuEntry)
+	if m.lists[tinyLfuProbation].Len()+m.lists[tinyLfuProtected].Len() < m.mainCap {
+		m.push(candidate, tinyLfuProbation)
+		return
+	}
+
+	victimElem := m.lists[tinyLfuProbation].Back()
+	if victimElem == nil {
+		victimElem = m.lists[tinyLfuProtected].Back()
+	}
+	if victimElem == nil {
+		delete(m.m, candidate.key)
+		return
+	}
+	victim := victimElem.Value.(*tinyLfuEntry)
+	if m.sketch.estimate(candidate.hash) > m.sketch.estimate(victim.hash) {
+		m.remove(victim)
+		m.push(candidate, tinyLfuProbation)
+	} else {
+		delete(m.m, candidate.key)
+	}
+}
+
+// 向Map中添加一个元素
+// Param：key 添加的对象key，value 添加的对象
+func (m *TinyLFU) Put(key, value interface{}) {
+	if e, ok := m.m[key]; ok {
+		m.sketch.increment(e.hash)
+		e.value = value
+		m.touch(e)
+		return
+	}
+	h := keyhash.Hash(key)
+	m.sketch.increment(h)
+	e := &tinyLfuEntry{key: key, value: value, hash: h}
+	m.push(e, tinyLfuWindow)
+	m.m[key] = e
+	m.admit()
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *TinyLFU) Get(key interface{}) (value interface{}, loaded bool) {
+	if e, ok := m.m[key]; ok {
+		m.sketch.increment(e.hash)
+		m.touch(e)
+		return e.value, true
+	}
+	// 未命中的访问同样计入频率，使再次出现的key更容易被接纳
+	m.sketch.increment(keyhash.Hash(key))
+	return nil, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *TinyLFU) Delete(key interface{}) {
+	if e, ok := m.m[key]; ok {
+		m.remove(e)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *TinyLFU) Size() int {
+	return len(m.m)
+}
+
+// Purge 清空缓存
+func (m *TinyLFU) Purge() {
+	m.m = map[interface{}]*tinyLfuEntry{}
+	for _, l := range m.lists {
+		l.Init()
+	}
+	m.sketch = newCmSketch(m.windowCap + m.mainCap)
+}
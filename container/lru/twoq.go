@@ -0,0 +1,150 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package lru
+
+import "container/list"
+
+const (
+	// Default2QRecentRatio 2Q中A1in队列占容量的默认比例
+	Default2QRecentRatio = 0.25
+	// Default2QGhostRatio 2Q中A1out队列占容量的默认比例
+	Default2QGhostRatio = 0.5
+)
+
+const (
+	twoQA1in = iota
+	twoQA1out
+	twoQAm
+)
+
+type twoQEntry struct {
+	key   interface{}
+	value interface{}
+	// 所在队列：twoQA1in twoQA1out twoQAm
+	where int
+	elem  *list.Element
+}
+
+// TwoQueue 2Q缓存
+// 新元素先进入FIFO队列A1in，从A1in淘汰的key记录在A1out中，
+// 只有在A1out中再次被访问的元素才进入LRU队列Am，避免只访问一次的元素冲刷热点数据
+type TwoQueue struct {
+	m     map[interface{}]*twoQEntry
+	lists [3]*list.List
+
+	cap    int
+	inCap  int
+	outCap int
+}
+
+func New2QCache(capacity int) *TwoQueue {
+	return New2QCacheWithRatio(capacity, Default2QRecentRatio, Default2QGhostRatio)
+}
+
+// New2QCacheWithRatio 创建2Q缓存
+// Param：recentRatio A1in队列占容量的比例，ghostRatio A1out队列占容量的比例
+func New2QCacheWithRatio(capacity int, recentRatio, ghostRatio float64) *TwoQueue {
+	ret := &TwoQueue{
+		m:      map[interface{}]*twoQEntry{},
+		cap:    capacity,
+		inCap:  int(float64(capacity) * recentRatio),
+		outCap: int(float64(capacity) * ghostRatio),
+	}
+	for i := range ret.lists {
+		ret.lists[i] = list.New()
+	}
+	return ret
+}
+
+func (m *TwoQueue) push(e *twoQEntry, where int) {
+	e.where = where
+	e.elem = m.lists[where].PushFront(e)
+}
+
+// 缓存已满时淘汰元素，A1in超出容量时淘汰到A1out，否则淘汰Am中最久未访问的元素
+func (m *TwoQueue) reclaim() {
+	if m.lists[twoQA1in].Len()+m.lists[twoQAm].Len() < m.cap {
+		return
+	}
+	if m.lists[twoQA1in].Len() > m.inCap || m.lists[twoQAm].Len() == 0 {
+		e := m.lists[twoQA1in].Remove(m.lists[twoQA1in].Back()).(*twoQEntry)
+		e.value = nil
+		m.push(e, twoQA1out)
+		if m.lists[twoQA1out].Len() > m.outCap {
+			out := m.lists[twoQA1out].Remove(m.lists[twoQA1out].Back()).(*twoQEntry)
+			delete(m.m, out.key)
+		}
+		return
+	}
+	e := m.lists[twoQAm].Remove(m.lists[twoQAm].Back()).(*twoQEntry)
+	delete(m.m, e.key)
+}
+
+// 向Map中添加一个元素
+// Param：key 添加的对象key，value 添加的对象
+func (m *TwoQueue) Put(key, value interface{}) {
+	if m.cap <= 0 {
+		return
+	}
+	if e, ok := m.m[key]; ok {
+		switch e.where {
+		case twoQAm:
+			e.value = value
+			m.lists[twoQAm].MoveToFront(e.elem)
+		case twoQA1in:
+			e.value = value
+		case twoQA1out:
+			m.lists[twoQA1out].Remove(e.elem)
+			m.reclaim()
+			e.value = value
+			m.push(e, twoQAm)
+		}
+		return
+	}
+	m.reclaim()
+	e := &twoQEntry{key: key, value: value}
+	m.push(e, twoQA1in)
+	m.m[key] = e
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *TwoQueue) Get(key interface{}) (value interface{}, loaded bool) {
+	if e, ok := m.m[key]; ok {
+		switch e.where {
+		case twoQAm:
+			m.lists[twoQAm].MoveToFront(e.elem)
+			return e.value, true
+		case twoQA1in:
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *TwoQueue) Delete(key interface{}) {
+	if e, ok := m.m[key]; ok {
+		m.lists[e.where].Remove(e.elem)
+		delete(m.m, key)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *TwoQueue) Size() int {
+	return m.lists[twoQA1in].Len() + m.lists[twoQAm].Len()
+}
+
+// Purge 清空缓存
+func (m *TwoQueue) Purge() {
+	m.m = map[interface{}]*twoQEntry{}
+	for _, l := range m.lists {
+		l.Init()
+	}
+}
@@ -0,0 +1,106 @@
+/**
+ * Copyright (C) 2018, Xiongfa Li.
+ * All right reserved.
+ * @author xiongfa.li
+ * @version V1.0
+ * Description:
+ */
+
+package container
+
+// PriorityBlockQueue 有界优先级阻塞队列，less(a, b)为true时a先出队，优先级相同时先入队的先出队
+// 阻塞、超时与关闭语义与BlockQueueOf相同
+type PriorityBlockQueue[T any] struct {
+	BlockQueueOf[T]
+}
+
+// NewPriorityBlockQueue 创建最多容纳maxSize个元素（小于0时不限制）的优先级阻塞队列
+func NewPriorityBlockQueue[T any](maxSize int, less func(a, b T) bool) *PriorityBlockQueue[T] {
+	return &PriorityBlockQueue[T]{
+		BlockQueueOf: BlockQueueOf[T]{
+			blocking: newBlocking(),
+			items: &priorityHeap[T]{
+				less: less,
+			},
+			maxSize: maxSize,
+		},
+	}
+}
+
+type heapItem[T any] struct {
+	value T
+	seq   uint64
+}
+
+// 二叉堆，seq保证相同优先级的元素按入队顺序出队
+type priorityHeap[T any] struct {
+	items []heapItem[T]
+	less  func(a, b T) bool
+	seq   uint64
+}
+
+func (h *priorityHeap[T]) len() int {
+	return len(h.items)
+}
+
+func (h *priorityHeap[T]) push(v T) {
+	h.seq++
+	h.items = append(h.items, heapItem[T]{value: v, seq: h.seq})
+	h.up(len(h.items) - 1)
+}
+
+func (h *priorityHeap[T]) front() T {
+	return h.items[0].value
+}
+
+func (h *priorityHeap[T]) pop() T {
+	n := len(h.items) - 1
+	v := h.items[0].value
+	h.items[0] = h.items[n]
+	h.items[n] = heapItem[T]{}
+	h.items = h.items[:n]
+	if n > 0 {
+		h.down(0)
+	}
+	return v
+}
+
+func (h *priorityHeap[T]) before(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.value, b.value) {
+		return true
+	}
+	if h.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *priorityHeap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.before(i, parent) {
+			return
+		}
+		h.items[i], h.items[parent] = h.items[parent], h.items[i]
+		i = parent
+	}
+}
+
+func (h *priorityHeap[T]) down(i int) {
+	n := len(h.items)
+	for {
+		min := i
+		if l := 2*i + 1; l < n && h.before(l, min) {
+			min = l
+		}
+		if r := 2*i + 2; r < n && h.before(r, min) {
+			min = r
+		}
+		if min == i {
+			return
+		}
+		h.items[i], h.items[min] = h.items[min], h.items[i]
+		i = min
+	}
+}
@@ -39,19 +39,73 @@ type PurgeExecutor interface {
 	Close() error
 }
 
+// PurgerRemover 支持移除清理器的清理执行器（可选）
+type PurgerRemover interface {
+	// RemovePurger 从清理执行器中移除清理器，移除后不再调用清理器的Purge方法
+	RemovePurger(purger Purger) error
+}
+
+// IntervalSetter 支持修改清理时间间隔的清理执行器（可选）
+type IntervalSetter interface {
+	// SetInterval 修改清理器的清理时间间隔
+	SetInterval(purger Purger, interval time.Duration) error
+}
+
+// StatsReporter 支持获得清理器运行指标的清理执行器（可选）
+type StatsReporter interface {
+	// Stats 获得清理器的运行指标，清理器不存在时返回false
+	Stats(purger Purger) (PurgeStats, bool)
+}
+
+// PurgeStats 清理器运行指标
+type PurgeStats struct {
+	// 清理时间间隔
+	Interval time.Duration
+	// 清理次数
+	Runs int64
+	// Purge发生panic的次数
+	Panics int64
+	// 最后一次清理的开始时间，未清理过为零值
+	LastRun time.Time
+	// 最后一次清理的耗时
+	LastDuration time.Duration
+}
+
+// ErrorHandler 清理器Purge发生panic时的回调
+type ErrorHandler func(purger Purger, err error)
+
+// 定时任务，移除或者修改时间间隔时取消时间轮中的定时器并释放清理器
+type task struct {
+	interval time.Duration
+	timer    timewheel.Timer
+
+	lock sync.Mutex
+	// 任务停用后为nil，已经触发的定时回调不再执行清理
+	purger Purger
+	stats  PurgeStats
+}
+
 type defaultExecutor struct {
 	tw      timewheel.TimeWheel
-	purgers map[Purger]struct{}
+	purgers map[Purger]*task
+	onError ErrorHandler
 	lock    sync.Mutex
 	once    sync.Once
 }
 
+var (
+	_ PurgeExecutor  = (*defaultExecutor)(nil)
+	_ PurgerRemover  = (*defaultExecutor)(nil)
+	_ IntervalSetter = (*defaultExecutor)(nil)
+	_ StatsReporter  = (*defaultExecutor)(nil)
+)
+
 type opt func(*defaultExecutor)
 
 // New 创建默认的清理执行器
 func New(opts ...opt) *defaultExecutor {
 	ret := &defaultExecutor{
-		purgers: make(map[Purger]struct{}),
+		purgers: make(map[Purger]*task),
 		tw: timewheel.NewAsyncHiera(
 			24*time.Hour,
 			[]time.Duration{time.Hour, time.Minute, time.Second, 100 * time.Millisecond},
@@ -68,17 +122,127 @@ func (e *defaultExecutor) AddPurger(purger Purger, interval time.Duration) error
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
+	if e.purgers == nil {
+		return fmt.Errorf("Purge executor has been closed ")
+	}
 	if _, ok := e.purgers[purger]; ok {
 		return fmt.Errorf("Purger has been added to executor ")
 	}
-	_, err := e.tw.Add(purger.Purge, interval, true)
+	t, err := e.schedule(purger, interval, PurgeStats{})
+	if err != nil {
+		return err
+	}
+	e.purgers[purger] = t
+	return nil
+}
+
+func (e *defaultExecutor) RemovePurger(purger Purger) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	t, ok := e.purgers[purger]
+	if !ok {
+		return fmt.Errorf("Purger not found in executor ")
+	}
+	t.deactivate()
+	delete(e.purgers, purger)
+	return nil
+}
+
+func (e *defaultExecutor) SetInterval(purger Purger, interval time.Duration) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	old, ok := e.purgers[purger]
+	if !ok {
+		return fmt.Errorf("Purger not found in executor ")
+	}
+	if old.interval == interval {
+		return nil
+	}
+	t, err := e.schedule(purger, interval, old.snapshot())
 	if err != nil {
 		return err
 	}
-	e.purgers[purger] = struct{}{}
+	old.deactivate()
+	e.purgers[purger] = t
 	return nil
 }
 
+func (e *defaultExecutor) Stats(purger Purger) (PurgeStats, bool) {
+	e.lock.Lock()
+	t, ok := e.purgers[purger]
+	e.lock.Unlock()
+
+	if !ok {
+		return PurgeStats{}, false
+	}
+	return t.snapshot(), true
+}
+
+// 需要在持有锁时调用
+func (e *defaultExecutor) schedule(purger Purger, interval time.Duration, stats PurgeStats) (*task, error) {
+	stats.Interval = interval
+	t := &task{
+		purger:   purger,
+		interval: interval,
+		stats:    stats,
+	}
+	timer, err := e.tw.Add(func() {
+		e.run(t)
+	}, interval, true)
+	if err != nil {
+		return nil, err
+	}
+	t.timer = timer
+	return t, nil
+}
+
+// 执行清理，恢复Purge中的panic并交给ErrorHandler处理
+func (e *defaultExecutor) run(t *task) {
+	t.lock.Lock()
+	purger := t.purger
+	t.lock.Unlock()
+	if purger == nil {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		o := recover()
+		t.lock.Lock()
+		t.stats.Runs++
+		t.stats.LastRun = start
+		t.stats.LastDuration = time.Since(start)
+		if o != nil {
+			t.stats.Panics++
+		}
+		t.lock.Unlock()
+
+		if o != nil && e.onError != nil {
+			e.onError(purger, fmt.Errorf("Purger panic: %v ", o))
+		}
+	}()
+	purger.Purge()
+}
+
+// 取消定时器并释放清理器，避免时间轮继续持有已移除的清理器
+func (t *task) deactivate() {
+	if t.timer != nil {
+		t.timer.Cancel()
+	}
+	t.lock.Lock()
+	t.purger = nil
+	t.lock.Unlock()
+}
+
+func (t *task) snapshot() PurgeStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.stats
+}
+
 func (e *defaultExecutor) Close() error {
 	e.once.Do(func() {
 		if e.tw != nil {
@@ -86,8 +250,9 @@ func (e *defaultExecutor) Close() error {
 		}
 		e.lock.Lock()
 		defer e.lock.Unlock()
-		for p := range e.purgers {
-			p.Purge()
+		for _, t := range e.purgers {
+			e.run(t)
+			t.deactivate()
 		}
 		e.purgers = nil
 	})
@@ -106,3 +271,10 @@ func (o options) TimeWheel(tw timewheel.TimeWheel) opt {
 		}
 	}
 }
+
+// ErrorHandler 配置清理器Purge发生panic时的回调，默认忽略
+func (o options) ErrorHandler(handler ErrorHandler) opt {
+	return func(executor *defaultExecutor) {
+		executor.onError = handler
+	}
+}
@@ -19,6 +19,7 @@ package recycleMap
 import (
 	"fmt"
 	"github.com/xfali/goutils/v2/container/purger"
+	"io"
 	"math"
 	"reflect"
 	"regexp"
@@ -37,6 +38,14 @@ const (
 type dataEntity[V any] struct {
 	value      V
 	expireTime time.Time
+	// 在过期索引中的位置，不在索引中为-1
+	heapIndex int
+	// 在keys中的位置
+	keyIndex int
+}
+
+func (e *dataEntity[V]) expired(now time.Time) bool {
+	return !e.expireTime.IsZero() && !e.expireTime.After(now)
 }
 
 type DeleteNotifier[K comparable, V any] func(key K, value V)
@@ -45,11 +54,32 @@ type defaultRecycleMap[K comparable, V any] struct {
 	purgeInterval time.Duration
 	purgeNumber   int64
 	manualPurge   bool
+	executor      purger.PurgeExecutor
 
 	matcher  MatchFunc[K]
+	equal    func(a, b V) bool
 	notifier DeleteNotifier[K, V]
 	db       map[K]*dataEntity[V]
 	lock     sync.Locker
+	expiry   expiryHeap[K, V]
+	// 所有key，用于Scan游标遍历
+	keys []K
+
+	codec Codec
+	aof   *appendLog
+
+	// 被事务监视的key
+	watched map[K]map[*defaultTx[K, V]]struct{}
+
+	subscribers map[*subscriber[K, V]]struct{}
+
+	loads        *loadState[K, V]
+	loadTTL      time.Duration
+	negativeTTL  time.Duration
+	refreshAhead time.Duration
+
+	// 分片map的key hash函数
+	hasher func(key K) uint64
 }
 
 type Opt[K comparable, V any] func(*defaultRecycleMap[K, V])
@@ -61,22 +91,33 @@ func New[K comparable, V any](opts ...Opt[K, V]) RecycleMap[K, V] {
 		purgeNumber:   DefaultPurgeNumberPerTime,
 		db:            map[K]*dataEntity[V]{},
 		matcher:       defaultMatch[K],
+		equal:         defaultEqual[V],
 		lock:          &sync.Mutex{},
+		codec:         GobCodec,
+		loads:         newLoadState[K, V](),
+		loadTTL:       -1,
 	}
 	for _, opt := range opts {
 		opt(ret)
 	}
 
-	if !ret.manualPurge {
-		err := execInstance().AddPurger(ret, ret.purgeInterval)
-		if err != nil {
-			panic(err)
-		}
-	}
+	ret.startPurge(ret)
 
 	return ret
 }
 
+func (dm *defaultRecycleMap[K, V]) startPurge(p purger.Purger) {
+	if dm.manualPurge {
+		return
+	}
+	if dm.executor == nil {
+		dm.executor = execInstance()
+	}
+	if err := dm.executor.AddPurger(p, dm.purgeInterval); err != nil {
+		panic(err)
+	}
+}
+
 func (dm *defaultRecycleMap[K, V]) Keys(pattern K) []K {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
@@ -92,44 +133,62 @@ func (dm *defaultRecycleMap[K, V]) Keys(pattern K) []K {
 }
 
 func (dm *defaultRecycleMap[K, V]) Purge() {
+	now := time.Now()
+	dm.purgeNegative(now)
+
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 
-	now := time.Now()
 	var i int64 = 0
-	for k, v := range dm.db {
-		if v.expireTime.IsZero() {
-			continue
-		}
-		if !v.expireTime.After(now) {
-			dm.innerDelete(k, v.value)
-			i++
-			if i >= dm.purgeNumber {
-				return
-			}
+	for len(dm.expiry) > 0 && i < dm.purgeNumber {
+		top := dm.expiry[0]
+		if !top.entity.expired(now) {
+			return
 		}
+		dm.innerDelete(top.key, top.entity.value, EventExpire)
+		i++
 	}
 }
 
-// 关闭
+// 关闭，从清理执行器中移除并清理过期key
 func (dm *defaultRecycleMap[K, V]) Close() error {
+	if !dm.manualPurge {
+		// 重复关闭时已经移除，忽略错误
+		if r, ok := dm.executor.(purger.PurgerRemover); ok {
+			r.RemovePurger(dm)
+		}
+	}
 	dm.Purge()
 	return nil
 }
 
 // 设置一个值，含过期时间
-// 如果expireIn设置为-1，则永不过期
+// 如果expireIn设置为-1，则永不过期；设置为KeepTTL则保留key原来的过期时间
 func (dm *defaultRecycleMap[K, V]) Set(key K, value V, expireIn time.Duration) error {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 
+	return dm.innerSet(key, value, expireIn)
+}
+
+func (dm *defaultRecycleMap[K, V]) innerSet(key K, value V, expireIn time.Duration) error {
+	now := time.Now()
+	event := EventSet
+	v := &dataEntity[V]{value: value}
+	if old, ok := dm.db[key]; ok && !old.expired(now) {
+		event = EventUpdate
+		if expireIn == KeepTTL {
+			v.expireTime = old.expireTime
+		}
+	}
 	if expireIn >= 0 {
-		dm.db[key] = &dataEntity[V]{value: value, expireTime: time.Now().Add(expireIn)}
-	} else {
-		dm.db[key] = &dataEntity[V]{value: value}
+		v.expireTime = now.Add(expireIn)
 	}
+	dm.put(key, v)
+	dm.touch(key)
+	dm.publish(event, key, value)
 
-	return nil
+	return dm.log(opSet, key, value, v.expireTime)
 }
 
 // 根据key获取value
@@ -137,29 +196,30 @@ func (dm *defaultRecycleMap[K, V]) Get(key K) V {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 
-	v, ok := dm.db[key]
-	if ok {
-		// expired
-		if v.expireTime.IsZero() {
-			return v.value
-		}
-		now := time.Now()
-		if !v.expireTime.After(now) {
-			dm.innerDelete(key, v.value)
-			var v V
-			return v
-		}
+	if v, ok := dm.lookup(key, time.Now()); ok {
 		return v.value
-	} else {
-		var v V
-		return v
 	}
+	var v V
+	return v
+}
+
+// 获取未过期的key，已过期的key被删除，需要在持有map锁时调用
+func (dm *defaultRecycleMap[K, V]) lookup(key K, now time.Time) (*dataEntity[V], bool) {
+	v, ok := dm.db[key]
+	if !ok {
+		return nil, false
+	}
+	if v.expired(now) {
+		dm.innerDelete(key, v.value, EventExpire)
+		return nil, false
+	}
+	return v, true
 }
 
 // 获得总数
 func (dm *defaultRecycleMap[K, V]) Size() int64 {
 	dm.lock.Lock()
-	dm.lock.Unlock()
+	defer dm.lock.Unlock()
 
 	var size int64 = 0
 	now := time.Now()
@@ -169,7 +229,7 @@ func (dm *defaultRecycleMap[K, V]) Size() int64 {
 			continue
 		}
 		if !v.expireTime.After(now) {
-			dm.innerDelete(k, v.value)
+			dm.innerDelete(k, v.value, EventExpire)
 		} else {
 			size++
 		}
@@ -182,19 +242,34 @@ func (dm *defaultRecycleMap[K, V]) Delete(keys ...K) int64 {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 
+	return dm.innerDeleteKeys(keys...)
+}
+
+func (dm *defaultRecycleMap[K, V]) innerDeleteKeys(keys ...K) int64 {
 	var total int64 = 0
 	for _, key := range keys {
 		if v, ok := dm.db[key]; ok {
-			dm.innerDelete(key, v.value)
+			dm.innerDelete(key, v.value, EventDelete)
+			dm.log(opDelete, key, v.value, time.Time{})
 			total++
 		}
 	}
 	return total
 }
 
-func (dm *defaultRecycleMap[K, V]) innerDelete(key K, value V) {
+// 删除key，event为0时（恢复数据）不调用DeleteNotifier也不发布事件
+func (dm *defaultRecycleMap[K, V]) innerDelete(key K, value V, event EventType) {
+	if v, ok := dm.db[key]; ok {
+		dm.unindex(v)
+		dm.removeKey(v)
+	}
 	delete(dm.db, key)
+	dm.touch(key)
+	if event == 0 {
+		return
+	}
 	dm.notifyDelete(key, value)
+	dm.publish(event, key, value)
 }
 
 func (dm *defaultRecycleMap[K, V]) notifyDelete(key K, value V) {
@@ -204,17 +279,27 @@ func (dm *defaultRecycleMap[K, V]) notifyDelete(key K, value V) {
 }
 
 // 根据key设置key过期时间
+// 如果expireIn小于0（KeepTTL除外），则永不过期；设置为KeepTTL时不修改过期时间，只返回key是否存在
 func (dm *defaultRecycleMap[K, V]) SetExpire(key K, expireIn time.Duration) bool {
 	dm.lock.Lock()
 	defer dm.lock.Unlock()
 
+	return dm.innerSetExpire(key, expireIn)
+}
+
+func (dm *defaultRecycleMap[K, V]) innerSetExpire(key K, expireIn time.Duration) bool {
 	v, ok := dm.db[key]
 	if ok {
+		if expireIn == KeepTTL {
+			return true
+		}
 		if expireIn >= 0 {
-			v.expireTime = time.Now().Add(expireIn)
+			dm.updateExpire(key, v, time.Now().Add(expireIn))
 		} else {
-			v.expireTime = time.Time{}
+			dm.updateExpire(key, v, time.Time{})
 		}
+		dm.touch(key)
+		dm.log(opExpire, key, v.value, v.expireTime)
 		return true
 	} else {
 		return false
@@ -288,13 +373,9 @@ func OptManualPurge[K comparable, V any]() Opt[K, V] {
 // 设置时间间隔越长内存消耗越多。
 func OptAutoPurge[K comparable, V any](interval time.Duration, executor purger.PurgeExecutor) Opt[K, V] {
 	return func(recycleMap *defaultRecycleMap[K, V]) {
-		if executor == nil {
-			executor = execInstance()
-		}
-		recycleMap.manualPurge = true
-		if err := executor.AddPurger(recycleMap, interval); err != nil {
-			panic(err)
-		}
+		recycleMap.manualPurge = false
+		recycleMap.purgeInterval = interval
+		recycleMap.executor = executor
 	}
 }
 
@@ -328,23 +409,30 @@ func OptSetLocker[K comparable, V any](locker sync.Locker) Opt[K, V] {
 	}
 }
 
-// OptSetMatcher 配置锁
-func OptSetMatcher[K comparable, V any](matcher MatchFunc[K]) Opt[K, V] {
+// OptSetCodec 配置快照与追加日志的编解码器（默认GobCodec）
+func OptSetCodec[K comparable, V any](codec Codec) Opt[K, V] {
 	return func(recycleMap *defaultRecycleMap[K, V]) {
-		recycleMap.matcher = matcher
+		if codec != nil {
+			recycleMap.codec = codec
+		}
 	}
 }
 
-// Multi 开启事务
-func (dm *defaultRecycleMap[K, V]) Multi() error {
-	//dm.Lock.Lock()
-	return nil
+// OptAppendLog 配置追加日志，所有Set、Delete、SetExpire操作都会追加写入w，重启后可以通过Restore恢复
+// fsync为true并且w实现了Sync方法（如*os.File）时每次写入后调用Sync，保证机器崩溃后数据不丢失
+// 写入失败后不再继续写入日志，Set返回该错误
+// 重启后使用RestoreAppendLog恢复数据可以截断崩溃时写入不完整的最后一条记录，之后继续追加到同一个文件
+func OptAppendLog[K comparable, V any](w io.Writer, fsync bool) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.aof = &appendLog{w: w, fsync: fsync}
+	}
 }
 
-// Exec 执行事务
-func (dm *defaultRecycleMap[K, V]) Exec() error {
-	//dm.Lock.Unlock()
-	return nil
+// OptSetMatcher 配置锁
+func OptSetMatcher[K comparable, V any](matcher MatchFunc[K]) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.matcher = matcher
+	}
 }
 
 var (
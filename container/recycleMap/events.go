@@ -0,0 +1,119 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+// EventType 键空间事件类型，可以按位组合订阅
+type EventType int
+
+const (
+	// EventSet 新增key
+	EventSet EventType = 1 << iota
+	// EventUpdate 覆盖已存在的key
+	EventUpdate
+	// EventDelete 调用Delete删除key
+	EventDelete
+	// EventExpire key过期被删除，包括Purge回收和访问时被动删除
+	EventExpire
+	// EventEvict key因容量或淘汰策略被移除，RecycleMap目前没有容量限制，不会产生该事件
+	EventEvict
+
+	// EventAll 所有事件
+	EventAll = EventSet | EventUpdate | EventDelete | EventExpire | EventEvict
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	default:
+		return "unknown"
+	}
+}
+
+// Event 键空间事件
+type Event[K comparable, V any] struct {
+	Type  EventType
+	Key   K
+	Value V
+}
+
+// EventListener 事件回调函数
+// 注意：回调在map锁内调用，不能在回调中再调用该map的方法，耗时操作请自行转到其他协程处理
+type EventListener[K comparable, V any] func(e Event[K, V])
+
+type subscriber[K comparable, V any] struct {
+	types    EventType
+	match    func(key K) bool
+	listener EventListener[K, V]
+}
+
+// Subscribe 订阅与pattern匹配（使用配置的MatchFunc）的key的types事件，返回取消订阅的函数
+// listener在map锁内按修改顺序同步调用，不能在listener中调用该map的任何方法（包括取消订阅），否则会死锁；
+// 需要访问map时请使用SubscribeChan或者在listener中转到其他协程处理。
+// Restore恢复数据时不产生事件。
+func (dm *defaultRecycleMap[K, V]) Subscribe(pattern K, types EventType, listener EventListener[K, V]) (cancel func()) {
+	s := &subscriber[K, V]{
+		types:    types,
+		match:    dm.matcher(pattern),
+		listener: listener,
+	}
+
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	if dm.subscribers == nil {
+		dm.subscribers = map[*subscriber[K, V]]struct{}{}
+	}
+	dm.subscribers[s] = struct{}{}
+	return func() {
+		dm.lock.Lock()
+		defer dm.lock.Unlock()
+
+		delete(dm.subscribers, s)
+	}
+}
+
+// SubscribeChan 与Subscribe相同，事件发送到ch
+// 发送不会阻塞，ch已满时事件被丢弃，请为ch设置足够的缓冲
+func (dm *defaultRecycleMap[K, V]) SubscribeChan(pattern K, types EventType, ch chan<- Event[K, V]) (cancel func()) {
+	return dm.Subscribe(pattern, types, func(e Event[K, V]) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
+
+// 发布事件，需要在持有map锁时调用
+func (dm *defaultRecycleMap[K, V]) publish(t EventType, key K, value V) {
+	if len(dm.subscribers) == 0 {
+		return
+	}
+	for s := range dm.subscribers {
+		if s.types&t != 0 && s.match(key) {
+			s.listener(Event[K, V]{Type: t, Key: key, Value: value})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"container/heap"
+	"time"
+)
+
+type expiryItem[K comparable, V any] struct {
+	key    K
+	entity *dataEntity[V]
+}
+
+// 按过期时间排序的最小堆，只包含设置了过期时间的key，Purge只需要从堆顶取出已过期的key
+type expiryHeap[K comparable, V any] []expiryItem[K, V]
+
+func (h expiryHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap[K, V]) Less(i, j int) bool {
+	return h[i].entity.expireTime.Before(h[j].entity.expireTime)
+}
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].entity.heapIndex = i
+	h[j].entity.heapIndex = j
+}
+
+func (h *expiryHeap[K, V]) Push(x interface{}) {
+	item := x.(expiryItem[K, V])
+	item.entity.heapIndex = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = expiryItem[K, V]{}
+	*h = old[:n-1]
+	item.entity.heapIndex = -1
+	return item
+}
+
+// 以下方法需要在持有map锁时调用
+
+// 保存key并维护过期索引
+func (dm *defaultRecycleMap[K, V]) put(key K, e *dataEntity[V]) {
+	if old, ok := dm.db[key]; ok {
+		dm.unindex(old)
+		e.keyIndex = old.keyIndex
+	} else {
+		e.keyIndex = len(dm.keys)
+		dm.keys = append(dm.keys, key)
+	}
+	e.heapIndex = -1
+	dm.db[key] = e
+	if !e.expireTime.IsZero() {
+		heap.Push(&dm.expiry, expiryItem[K, V]{key: key, entity: e})
+	}
+}
+
+// 修改过期时间并维护过期索引，expireTime为零值表示永不过期
+func (dm *defaultRecycleMap[K, V]) updateExpire(key K, e *dataEntity[V], expireTime time.Time) {
+	e.expireTime = expireTime
+	switch {
+	case expireTime.IsZero():
+		dm.unindex(e)
+	case e.heapIndex >= 0:
+		heap.Fix(&dm.expiry, e.heapIndex)
+	default:
+		heap.Push(&dm.expiry, expiryItem[K, V]{key: key, entity: e})
+	}
+}
+
+func (dm *defaultRecycleMap[K, V]) unindex(e *dataEntity[V]) {
+	if e.heapIndex >= 0 {
+		heap.Remove(&dm.expiry, e.heapIndex)
+	}
+}
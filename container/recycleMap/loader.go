@@ -0,0 +1,171 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"github.com/xfali/goutils/v2/container/internal/singleflight"
+	"sync"
+	"time"
+)
+
+// Loader key不存在时加载key对应的值
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// ErrLoaderPanic loader发生panic，等待同一次加载的其他调用者返回该错误（包装了panic的值）
+var ErrLoaderPanic = singleflight.ErrPanic
+
+type negativeEntry struct {
+	err        error
+	expireTime time.Time
+}
+
+// GetOrLoad的加载状态，每个分片独立
+type loadState[K comparable, V any] struct {
+	group singleflight.Group[K, V]
+
+	negLock   sync.Mutex
+	negatives map[K]negativeEntry
+}
+
+func newLoadState[K comparable, V any]() *loadState[K, V] {
+	return &loadState[K, V]{
+		negatives: map[K]negativeEntry{},
+	}
+}
+
+// GetOrLoad 获取key对应的值，key不存在或已过期时调用loader加载并以OptLoadTTL配置的过期时间设置
+// 同一个key的并发未命中只会调用一次loader，其他调用者等待并共享加载结果。
+// 配置OptNegativeTTL后加载失败的错误会被缓存，有效期内直接返回该错误而不再调用loader；
+// 配置OptRefreshAhead后命中的key剩余过期时间小于配置值时会异步重新加载，本次调用仍返回当前值。
+// 加载期间key被修改、删除或者修改过期时间时，加载结果只返回给调用者而不设置到map中。
+// loader发生panic时执行loader的调用者重新panic，其他等待的调用者返回ErrLoaderPanic。
+func (dm *defaultRecycleMap[K, V]) GetOrLoad(key K, loader Loader[K, V]) (V, error) {
+	now := time.Now()
+	dm.lock.Lock()
+	v, ok := dm.lookup(key, now)
+	var value V
+	var ttl time.Duration
+	if ok {
+		value, ttl = v.value, v.ttl(now)
+	}
+	dm.lock.Unlock()
+
+	if ok {
+		if dm.refreshAhead > 0 && ttl >= 0 && ttl <= dm.refreshAhead {
+			dm.loads.group.DoAsync(key, func(stale func() bool) (V, error) {
+				return dm.load(key, loader, stale)
+			})
+		}
+		return value, nil
+	}
+	if err := dm.negative(key, now); err != nil {
+		return value, err
+	}
+	return dm.loads.group.Do(key, func(stale func() bool) (V, error) {
+		return dm.load(key, loader, stale)
+	})
+}
+
+func (dm *defaultRecycleMap[K, V]) load(key K, loader Loader[K, V], stale func() bool) (V, error) {
+	v, err := loader(key)
+	if err != nil {
+		if dm.negativeTTL > 0 {
+			dm.loads.negLock.Lock()
+			if !stale() {
+				dm.loads.negatives[key] = negativeEntry{err: err, expireTime: time.Now().Add(dm.negativeTTL)}
+			}
+			dm.loads.negLock.Unlock()
+		}
+		return v, err
+	}
+
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	if stale() {
+		return v, nil
+	}
+	return v, dm.innerSet(key, v, dm.loadTTL)
+}
+
+// 获得缓存的加载错误
+func (dm *defaultRecycleMap[K, V]) negative(key K, now time.Time) error {
+	if dm.negativeTTL <= 0 {
+		return nil
+	}
+	dm.loads.negLock.Lock()
+	defer dm.loads.negLock.Unlock()
+
+	if e, ok := dm.loads.negatives[key]; ok {
+		if e.expireTime.After(now) {
+			return e.err
+		}
+		delete(dm.loads.negatives, key)
+	}
+	return nil
+}
+
+// key被修改，正在执行的加载结果不再设置到map中，需要在持有map锁时调用
+func (dm *defaultRecycleMap[K, V]) forgetLoad(key K) {
+	dm.loads.group.Forget(key)
+	if dm.negativeTTL <= 0 {
+		return
+	}
+	dm.loads.negLock.Lock()
+	delete(dm.loads.negatives, key)
+	dm.loads.negLock.Unlock()
+}
+
+func (dm *defaultRecycleMap[K, V]) purgeNegative(now time.Time) {
+	if dm.negativeTTL <= 0 {
+		return
+	}
+	dm.loads.negLock.Lock()
+	defer dm.loads.negLock.Unlock()
+
+	for k, e := range dm.loads.negatives {
+		if !e.expireTime.After(now) {
+			delete(dm.loads.negatives, k)
+		}
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) GetOrLoad(key K, loader Loader[K, V]) (V, error) {
+	return s.shardOf(key).GetOrLoad(key, loader)
+}
+
+// OptLoadTTL 配置GetOrLoad加载的值的过期时间，含义与Set的expireIn相同，默认-1永不过期
+func OptLoadTTL[K comparable, V any](ttl time.Duration) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.loadTTL = ttl
+	}
+}
+
+// OptNegativeTTL 配置GetOrLoad加载失败的错误缓存时间，小于等于0表示不缓存错误（默认）
+func OptNegativeTTL[K comparable, V any](ttl time.Duration) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.negativeTTL = ttl
+	}
+}
+
+// OptRefreshAhead 配置GetOrLoad提前刷新时间，命中key的剩余过期时间小于等于d时异步重新加载
+// 只对有过期时间的key生效
+func OptRefreshAhead[K comparable, V any](d time.Duration) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.refreshAhead = d
+	}
+}
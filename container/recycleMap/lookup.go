@@ -0,0 +1,111 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import "time"
+
+// Entry MSet批量设置的元素，ExpireIn含义与Set相同
+type Entry[K comparable, V any] struct {
+	Key      K
+	Value    V
+	ExpireIn time.Duration
+}
+
+// 剩余过期时间，永不过期返回-1
+func (e *dataEntity[V]) ttl(now time.Time) time.Duration {
+	if e.expireTime.IsZero() {
+		return -1
+	}
+	return e.expireTime.Sub(now)
+}
+
+// Lookup 根据key获取value，key不存在或已过期时ok为false
+func (dm *defaultRecycleMap[K, V]) Lookup(key K) (value V, ok bool) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	if v, ok := dm.lookup(key, time.Now()); ok {
+		return v.value, true
+	}
+	return value, false
+}
+
+// GetWithTTL 根据key获取value及剩余过期时间，永不过期的key返回-1
+func (dm *defaultRecycleMap[K, V]) GetWithTTL(key K) (value V, ttl time.Duration, ok bool) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	now := time.Now()
+	if v, ok := dm.lookup(key, now); ok {
+		return v.value, v.ttl(now), true
+	}
+	return value, -2, false
+}
+
+// MGet 批量获取，返回存在的key及value
+func (dm *defaultRecycleMap[K, V]) MGet(keys ...K) map[K]V {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	now := time.Now()
+	ret := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if v, ok := dm.lookup(key, now); ok {
+			ret[key] = v.value
+		}
+	}
+	return ret
+}
+
+// MSet 批量设置，所有key在一次加锁中原子设置
+func (dm *defaultRecycleMap[K, V]) MSet(entries ...Entry[K, V]) error {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	var err error
+	for _, e := range entries {
+		if e2 := dm.innerSet(e.Key, e.Value, e.ExpireIn); e2 != nil && err == nil {
+			err = e2
+		}
+	}
+	return err
+}
+
+// Range 遍历调用时所有未过期key的快照，f返回false时停止遍历
+func (dm *defaultRecycleMap[K, V]) Range(f func(key K, value V, ttl time.Duration) bool) {
+	type item struct {
+		key   K
+		value V
+		ttl   time.Duration
+	}
+	dm.lock.Lock()
+	now := time.Now()
+	items := make([]item, 0, len(dm.db))
+	for k, v := range dm.db {
+		if v.expired(now) {
+			continue
+		}
+		items = append(items, item{key: k, value: v.value, ttl: v.ttl(now)})
+	}
+	dm.lock.Unlock()
+
+	for _, i := range items {
+		if !f(i.key, i.value, i.ttl) {
+			return
+		}
+	}
+}
@@ -0,0 +1,316 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Encoder 编码器
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder 解码器
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// Codec 持久化编解码器，用于快照与追加日志
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+var (
+	// GobCodec gob编解码器（默认），key与value中的接口类型需要通过gob.Register注册
+	GobCodec Codec = gobCodec{}
+	// JSONCodec json编解码器
+	JSONCodec Codec = jsonCodec{}
+)
+
+const (
+	opSet uint8 = iota + 1
+	opDelete
+	opExpire
+)
+
+// 持久化记录，快照与追加日志使用相同的格式
+type record[K comparable, V any] struct {
+	Op    uint8
+	Key   K
+	Value V
+	// 过期的绝对时间（UnixNano），0表示永不过期
+	ExpireAt int64
+}
+
+func expireAt(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func expireTime(at int64) time.Time {
+	if at == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, at)
+}
+
+const (
+	// 记录头：4字节数据长度 + 4字节CRC32
+	recordHeaderSize = 8
+	// 单条记录的最大长度
+	maxRecordSize = 64 << 20
+)
+
+var (
+	// ErrCorruptRecord 记录长度非法或者校验失败
+	ErrCorruptRecord = errors.New("RecycleMap: corrupt record ")
+	// ErrRecordTooLarge 记录超出最大长度
+	ErrRecordTooLarge = errors.New("RecycleMap: record too large ")
+)
+
+// RecordError 读取快照或者追加日志失败，Offset为最后一条完整记录结束的位置
+// Err为io.ErrUnexpectedEOF时表示最后一条记录不完整（如写入追加日志时进程崩溃），
+// 将文件截断到Offset后可以继续追加
+type RecordError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("RecycleMap: read record at offset %d failed: %v", e.Offset, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// 每条记录单独编码并以长度及CRC32作为记录头，
+// 保证不同进程追加到同一个文件的记录可以独立解码，也能识别崩溃时写入不完整的最后一条记录
+func writeRecord[K comparable, V any](w io.Writer, codec Codec, r *record[K, V]) error {
+	buf := bytes.Buffer{}
+	buf.Write(make([]byte, recordHeaderSize))
+	if err := codec.NewEncoder(&buf).Encode(r); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	size := len(data) - recordHeaderSize
+	if size > maxRecordSize {
+		return ErrRecordTooLarge
+	}
+	binary.BigEndian.PutUint32(data, uint32(size))
+	binary.BigEndian.PutUint32(data[4:], crc32.ChecksumIEEE(data[recordHeaderSize:]))
+	_, err := w.Write(data)
+	return err
+}
+
+// 读取一条记录并返回读取的字节数，数据结束返回io.EOF，最后一条记录不完整返回io.ErrUnexpectedEOF，
+// 记录损坏返回ErrCorruptRecord
+func readRecord[K comparable, V any](r io.Reader, codec Codec, rec *record[K, V]) (int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxRecordSize {
+		return 0, ErrCorruptRecord
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return 0, ErrCorruptRecord
+	}
+	if err := codec.NewDecoder(bytes.NewReader(data)).Decode(rec); err != nil {
+		return 0, err
+	}
+	return recordHeaderSize + int64(size), nil
+}
+
+type appendLog struct {
+	w     io.Writer
+	fsync bool
+	lock  sync.Mutex
+	err   error
+}
+
+func (l *appendLog) write(encode func(w io.Writer) error) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	if err := encode(l.w); err != nil {
+		if err == ErrRecordTooLarge {
+			// 没有写入任何数据，日志仍然可用
+			return err
+		}
+		l.err = fmt.Errorf("RecycleMap append log failed: %w ", err)
+		return l.err
+	}
+	if l.fsync {
+		if s, ok := l.w.(interface{ Sync() error }); ok {
+			if err := s.Sync(); err != nil {
+				l.err = fmt.Errorf("RecycleMap append log sync failed: %w ", err)
+				return l.err
+			}
+		}
+	}
+	return nil
+}
+
+// 追加写日志，需要在持有map锁时调用以保证日志顺序与修改顺序一致
+func (dm *defaultRecycleMap[K, V]) log(op uint8, key K, value V, expire time.Time) error {
+	if dm.aof == nil {
+		return nil
+	}
+	r := &record[K, V]{Op: op, Key: key, Value: value, ExpireAt: expireAt(expire)}
+	return dm.aof.write(func(w io.Writer) error {
+		return writeRecord(w, dm.codec, r)
+	})
+}
+
+// Snapshot 将所有未过期的key写入w，过期时间以绝对时间保存
+func (dm *defaultRecycleMap[K, V]) Snapshot(w io.Writer) error {
+	dm.lock.Lock()
+	now := time.Now()
+	records := make([]record[K, V], 0, len(dm.db))
+	for k, v := range dm.db {
+		if v.expired(now) {
+			continue
+		}
+		records = append(records, record[K, V]{Op: opSet, Key: k, Value: v.value, ExpireAt: expireAt(v.expireTime)})
+	}
+	dm.lock.Unlock()
+
+	for i := range records {
+		if err := writeRecord(w, dm.codec, &records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore 从r中读取Snapshot快照或者追加日志并应用到map，已存在的key会被覆盖，已过期的key被忽略
+// 恢复的数据不会写入追加日志，也不会调用DeleteNotifier或产生订阅事件
+// 读取失败时已读取的完整记录仍然生效，返回*RecordError，最后一条记录不完整时RecordError.Err为io.ErrUnexpectedEOF
+func (dm *defaultRecycleMap[K, V]) Restore(r io.Reader) error {
+	return restore(r, dm.codec, func(rec *record[K, V], now time.Time) {
+		dm.apply(rec, now)
+	})
+}
+
+func restore[K comparable, V any](r io.Reader, codec Codec, apply func(rec *record[K, V], now time.Time)) error {
+	now := time.Now()
+	var offset int64
+	for {
+		rec := record[K, V]{}
+		n, err := readRecord(r, codec, &rec)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return &RecordError{Offset: offset, Err: err}
+		}
+		offset += n
+		apply(&rec, now)
+	}
+}
+
+// RestoreAppendLog 从追加日志文件f的起始位置恢复数据，最后一条记录不完整时将f截断到最后一条完整记录结束的位置，
+// 返回后f的读写位置在文件末尾，可以继续通过OptAppendLog追加写入；记录损坏时返回*RecordError
+func RestoreAppendLog[K comparable, V any](m RecycleMap[K, V], f *os.File) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	err := m.Restore(bufio.NewReader(f))
+	if err != nil {
+		var recErr *RecordError
+		if !errors.As(err, &recErr) || recErr.Err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if err := f.Truncate(recErr.Offset); err != nil {
+			return err
+		}
+	}
+	_, err = f.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (dm *defaultRecycleMap[K, V]) apply(rec *record[K, V], now time.Time) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	expire := expireTime(rec.ExpireAt)
+	switch rec.Op {
+	case opSet:
+		if !expire.IsZero() && !expire.After(now) {
+			if v, ok := dm.db[rec.Key]; ok {
+				dm.innerDelete(rec.Key, v.value, 0)
+			}
+			return
+		}
+		dm.put(rec.Key, &dataEntity[V]{value: rec.Value, expireTime: expire})
+	case opDelete:
+		if v, ok := dm.db[rec.Key]; ok {
+			dm.innerDelete(rec.Key, v.value, 0)
+		}
+	case opExpire:
+		if v, ok := dm.db[rec.Key]; ok {
+			dm.updateExpire(rec.Key, v, expire)
+		}
+	}
+}
@@ -9,6 +9,7 @@
 package recycleMap
 
 import (
+	"io"
 	"time"
 )
 
@@ -19,13 +20,53 @@ type RecycleMap[K comparable, V any] interface {
 	// Get 根据key获取value
 	Get(key K) V
 
+	// SetNX key不存在时设置值，返回是否设置成功
+	SetNX(key K, value V, expireIn time.Duration) (bool, error)
+
+	// GetSet 设置新值并返回旧值，exists表示key原来是否存在
+	GetSet(key K, value V, expireIn time.Duration) (old V, exists bool, err error)
+
+	// GetAndDelete 获取并删除key
+	GetAndDelete(key K) (V, bool)
+
+	// CompareAndSwap key存在且当前值与old相等时设置为new，返回是否设置成功
+	CompareAndSwap(key K, old, new V, expireIn time.Duration) (bool, error)
+
+	// Update 根据当前值计算并设置新值，key不存在时exists为false，返回新值
+	Update(key K, fn func(old V, exists bool) V, expireIn time.Duration) (V, error)
+
+	// GetOrLoad 根据key获取value，key不存在或已过期时调用loader加载，同一个key的并发加载只调用一次loader
+	GetOrLoad(key K, loader Loader[K, V]) (V, error)
+
+	// Lookup 根据key获取value，key不存在或已过期时ok为false
+	Lookup(key K) (value V, ok bool)
+
+	// GetWithTTL 根据key获取value及剩余过期时间，永不过期的key返回-1
+	GetWithTTL(key K) (value V, ttl time.Duration, ok bool)
+
+	// MGet 批量获取，返回存在的key及value
+	MGet(keys ...K) map[K]V
+
+	// MSet 批量设置，每个key可以设置不同的过期时间
+	MSet(entries ...Entry[K, V]) error
+
+	// Range 遍历所有未过期的key，f返回false时停止遍历
+	// 遍历的是调用时的快照，f中可以调用该map的方法
+	Range(f func(key K, value V, ttl time.Duration) bool)
+
 	// Keys 获得所有匹配的key
 	Keys(pattern K) []K
 
+	// Scan 增量遍历与pattern匹配的key，cursor为0时开始遍历，返回的next为0时遍历结束
+	Scan(cursor uint64, pattern K, count int) (next uint64, keys []K)
+
+	// ScanFunc 与Scan相同，使用match过滤key（为nil时返回所有key），与配置的MatchFunc无关
+	ScanFunc(cursor uint64, match func(key K) bool, count int) (next uint64, keys []K)
+
 	// Delete 删除key
 	Delete(keys ...K) int64
 
-	// SetExpire 根据key设置key过期时间
+	// SetExpire 根据key设置key过期时间，expireIn为KeepTTL时不修改过期时间
 	SetExpire(key K, expireIn time.Duration) bool
 
 	// TTL 获得key过期时间
@@ -37,6 +78,22 @@ type RecycleMap[K comparable, V any] interface {
 	// Purge 回收过期key
 	Purge()
 
+	// Snapshot 将所有未过期的key写入w
+	Snapshot(w io.Writer) error
+
+	// Restore 从Snapshot快照或者追加日志中恢复数据，读取失败时返回*RecordError
+	Restore(r io.Reader) error
+
+	// Multi 开启事务
+	Multi() Tx[K, V]
+
+	// Subscribe 订阅与pattern匹配的key的事件，返回取消订阅的函数
+	// listener在map锁内调用，不能在listener中调用该map的方法
+	Subscribe(pattern K, types EventType, listener EventListener[K, V]) (cancel func())
+
+	// SubscribeChan 订阅与pattern匹配的key的事件，事件以非阻塞方式发送到ch
+	SubscribeChan(pattern K, types EventType, ch chan<- Event[K, V]) (cancel func())
+
 	// Close 关闭并回收所有资源
 	Close() error
 }
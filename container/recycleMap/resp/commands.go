@@ -0,0 +1,449 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xfali/goutils/v2/container/recycleMap"
+)
+
+type store = recycleMap.RecycleMap[string, []byte]
+
+type command struct {
+	// 与Redis相同：正数为参数个数（含命令名），负数为最少参数个数
+	arity   int
+	handler func(m store, args [][]byte) interface{}
+}
+
+func (c command) checkArity(n int) bool {
+	if c.arity >= 0 {
+		return n == c.arity
+	}
+	return n >= -c.arity
+}
+
+var (
+	errSyntax      = errors.New("ERR syntax error")
+	errNotInteger  = errors.New("ERR value is not an integer or out of range")
+	errOverflow    = errors.New("ERR increment or decrement would overflow")
+	errInvalidDB   = errors.New("ERR DB index is out of range")
+	errInvalidScan = errors.New("ERR invalid cursor")
+)
+
+func errUnknown(args [][]byte) error {
+	return fmt.Errorf("ERR unknown command '%s'", args[0])
+}
+
+func errArity(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+}
+
+func errStore(err error) error {
+	return fmt.Errorf("ERR %v", err)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":     {-1, cmdPing},
+		"ECHO":     {2, cmdEcho},
+		"SELECT":   {2, cmdSelect},
+		"CLIENT":   {-2, cmdClient},
+		"GET":      {2, cmdGet},
+		"SET":      {-3, cmdSet},
+		"SETNX":    {3, cmdSetNX},
+		"GETSET":   {3, cmdGetSet},
+		"GETDEL":   {2, cmdGetDel},
+		"MGET":     {-2, cmdMGet},
+		"MSET":     {-3, cmdMSet},
+		"DEL":      {-2, cmdDel},
+		"UNLINK":   {-2, cmdDel},
+		"EXISTS":   {-2, cmdExists},
+		"TYPE":     {2, cmdType},
+		"EXPIRE":   {3, cmdExpire(time.Second)},
+		"PEXPIRE":  {3, cmdExpire(time.Millisecond)},
+		"PERSIST":  {2, cmdPersist},
+		"TTL":      {2, cmdTTL(time.Second)},
+		"PTTL":     {2, cmdTTL(time.Millisecond)},
+		"KEYS":     {2, cmdKeys},
+		"SCAN":     {-2, cmdScan},
+		"INCR":     {2, cmdIncr(1, false)},
+		"DECR":     {2, cmdIncr(-1, false)},
+		"INCRBY":   {3, cmdIncr(1, true)},
+		"DECRBY":   {3, cmdIncr(-1, true)},
+		"DBSIZE":   {1, cmdDBSize},
+		"FLUSHDB":  {-1, cmdFlush},
+		"FLUSHALL": {-1, cmdFlush},
+	}
+}
+
+func cmdPing(m store, args [][]byte) interface{} {
+	switch len(args) {
+	case 1:
+		return simpleString("PONG")
+	case 2:
+		return args[1]
+	default:
+		return errArity("ping")
+	}
+}
+
+func cmdEcho(m store, args [][]byte) interface{} {
+	return args[1]
+}
+
+func cmdSelect(m store, args [][]byte) interface{} {
+	if string(args[1]) != "0" {
+		return errInvalidDB
+	}
+	return replyOK
+}
+
+func cmdClient(m store, args [][]byte) interface{} {
+	return replyOK
+}
+
+func cmdGet(m store, args [][]byte) interface{} {
+	if v, ok := m.Lookup(string(args[1])); ok {
+		return v
+	}
+	return nil
+}
+
+// SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|KEEPTTL]
+func cmdSet(m store, args [][]byte) interface{} {
+	key := string(args[1])
+	var nx, xx, get, hasExpire bool
+	expireIn := time.Duration(-1)
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			if hasExpire {
+				return errSyntax
+			}
+			hasExpire = true
+			expireIn = recycleMap.KeepTTL
+		case "EX", "PX":
+			if hasExpire || i+1 >= len(args) {
+				return errSyntax
+			}
+			hasExpire = true
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			if n <= 0 || n > int64(math.MaxInt64/unit) {
+				return errors.New("ERR invalid expire time in 'set' command")
+			}
+			expireIn = time.Duration(n) * unit
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+
+	old, exists := m.Lookup(key)
+	var oldReply interface{}
+	if exists {
+		oldReply = old
+	}
+	if (nx && exists) || (xx && !exists) {
+		if get {
+			return oldReply
+		}
+		return nil
+	}
+	if err := m.Set(key, args[2], expireIn); err != nil {
+		return errStore(err)
+	}
+	if get {
+		return oldReply
+	}
+	return replyOK
+}
+
+func cmdSetNX(m store, args [][]byte) interface{} {
+	ok, err := m.SetNX(string(args[1]), args[2], -1)
+	if err != nil {
+		return errStore(err)
+	}
+	return boolReply(ok)
+}
+
+func cmdGetSet(m store, args [][]byte) interface{} {
+	old, exists, err := m.GetSet(string(args[1]), args[2], -1)
+	if err != nil {
+		return errStore(err)
+	}
+	if !exists {
+		return nil
+	}
+	return old
+}
+
+func cmdGetDel(m store, args [][]byte) interface{} {
+	if v, ok := m.GetAndDelete(string(args[1])); ok {
+		return v
+	}
+	return nil
+}
+
+func cmdMGet(m store, args [][]byte) interface{} {
+	keys := toKeys(args[1:])
+	values := m.MGet(keys...)
+	ret := make([]interface{}, len(keys))
+	for i, k := range keys {
+		if v, ok := values[k]; ok {
+			ret[i] = v
+		}
+	}
+	return ret
+}
+
+func cmdMSet(m store, args [][]byte) interface{} {
+	if len(args)%2 != 1 {
+		return errArity("mset")
+	}
+	entries := make([]recycleMap.Entry[string, []byte], 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		entries = append(entries, recycleMap.Entry[string, []byte]{Key: string(args[i]), Value: args[i+1], ExpireIn: -1})
+	}
+	if err := m.MSet(entries...); err != nil {
+		return errStore(err)
+	}
+	return replyOK
+}
+
+func cmdDel(m store, args [][]byte) interface{} {
+	return m.Delete(toKeys(args[1:])...)
+}
+
+func cmdExists(m store, args [][]byte) interface{} {
+	var n int64
+	for _, k := range args[1:] {
+		if _, ok := m.Lookup(string(k)); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(m store, args [][]byte) interface{} {
+	if _, ok := m.Lookup(string(args[1])); ok {
+		return simpleString("string")
+	}
+	return simpleString("none")
+}
+
+func cmdExpire(unit time.Duration) func(m store, args [][]byte) interface{} {
+	return func(m store, args [][]byte) interface{} {
+		n, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil || n > int64(math.MaxInt64/unit) {
+			return errNotInteger
+		}
+		key := string(args[1])
+		if _, ok := m.Lookup(key); !ok {
+			return int64(0)
+		}
+		if n <= 0 {
+			// 与Redis相同，过期时间不为正数时直接删除
+			return m.Delete(key)
+		}
+		return boolReply(m.SetExpire(key, time.Duration(n)*unit))
+	}
+}
+
+func cmdPersist(m store, args [][]byte) interface{} {
+	key := string(args[1])
+	if _, ttl, ok := m.GetWithTTL(key); !ok || ttl == -1 {
+		return int64(0)
+	}
+	return boolReply(m.SetExpire(key, -1))
+}
+
+func cmdTTL(unit time.Duration) func(m store, args [][]byte) interface{} {
+	return func(m store, args [][]byte) interface{} {
+		_, ttl, ok := m.GetWithTTL(string(args[1]))
+		if !ok {
+			return int64(-2)
+		}
+		if ttl == -1 {
+			return int64(-1)
+		}
+		return int64((ttl + unit/2) / unit)
+	}
+}
+
+// KEYS与SCAN的pattern总是使用glob匹配，与RecycleMap配置的MatchFunc无关
+func cmdKeys(m store, args [][]byte) interface{} {
+	pattern := string(args[1])
+	var ret []interface{}
+	m.Range(func(key string, value []byte, ttl time.Duration) bool {
+		if recycleMap.GlobMatch(pattern, key) {
+			ret = append(ret, []byte(key))
+		}
+		return true
+	})
+	if ret == nil {
+		ret = []interface{}{}
+	}
+	return ret
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+func cmdScan(m store, args [][]byte) interface{} {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		return errInvalidScan
+	}
+	pattern := "*"
+	count := recycleMap.DefaultScanCount
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return errNotInteger
+			}
+			if n < 1 {
+				return errSyntax
+			}
+			count = n
+		default:
+			return errSyntax
+		}
+	}
+	next, keys := m.ScanFunc(cursor, func(key string) bool {
+		return recycleMap.GlobMatch(pattern, key)
+	}, count)
+	ret := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, []byte(k))
+	}
+	return []interface{}{[]byte(strconv.FormatUint(next, 10)), ret}
+}
+
+func cmdIncr(sign int64, withDelta bool) func(m store, args [][]byte) interface{} {
+	return func(m store, args [][]byte) interface{} {
+		delta := sign
+		if withDelta {
+			n, err := strconv.ParseInt(string(args[2]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			if sign < 0 {
+				if n == math.MinInt64 {
+					return errOverflow
+				}
+				n = -n
+			}
+			delta = n
+		}
+		key := string(args[1])
+		// 值不是整数或者溢出时不能写入，否则会产生更新事件并使WATCH的事务失败，
+		// 因此先计算再通过CompareAndSwap写入，期间值被修改则重试
+		for {
+			old, exists := m.Lookup(key)
+			var n int64
+			if exists {
+				v, err := strconv.ParseInt(string(old), 10, 64)
+				if err != nil {
+					return errNotInteger
+				}
+				n = v
+			}
+			if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+				return errOverflow
+			}
+			n += delta
+			value := []byte(strconv.FormatInt(n, 10))
+			var ok bool
+			var err error
+			if exists {
+				ok, err = m.CompareAndSwap(key, old, value, recycleMap.KeepTTL)
+			} else {
+				ok, err = m.SetNX(key, value, -1)
+			}
+			if err != nil {
+				return errStore(err)
+			}
+			if ok {
+				return n
+			}
+		}
+	}
+}
+
+func cmdDBSize(m store, args [][]byte) interface{} {
+	return m.Size()
+}
+
+func cmdFlush(m store, args [][]byte) interface{} {
+	m.Delete(liveKeys(m)...)
+	return replyOK
+}
+
+// 获得所有未过期的key，与RecycleMap配置的MatchFunc无关
+func liveKeys(m store) []string {
+	var ret []string
+	m.Range(func(key string, value []byte, ttl time.Duration) bool {
+		ret = append(ret, key)
+		return true
+	})
+	return ret
+}
+
+func boolReply(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toKeys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, k := range args {
+		keys[i] = string(k)
+	}
+	return keys
+}
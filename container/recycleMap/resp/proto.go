@@ -0,0 +1,170 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// 单个参数最大长度
+	maxBulkLen = 16 * 1024 * 1024
+	// 单条命令最大参数个数
+	maxArgs = 1024 * 1024
+	// 单行最大长度，与Redis inline命令最大长度相同
+	maxLineLen = 64 * 1024
+	// 预分配参数列表的最大长度，参数个数由客户端声明，不能按其直接分配
+	maxPreallocArgs = 64
+)
+
+var errProtocol = errors.New("ERR Protocol error")
+
+// 简单字符串回复
+type simpleString string
+
+// 空数组回复（EXEC被放弃时）
+type nilArray struct{}
+
+var (
+	replyOK     = simpleString("OK")
+	replyQueued = simpleString("QUEUED")
+)
+
+// 读取一条命令，支持RESP数组和inline命令
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return bytes.Fields(line), nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n <= 0 || n > maxArgs {
+		return nil, errProtocol
+	}
+	args := make([][]byte, 0, minInt(n, maxPreallocArgs))
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// 读取size字节的bulk字符串及结尾的\r\n
+// 缓冲区随实际读到的数据增长，声明了很大长度但不发送数据的客户端不会占用内存
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	data := buf.Bytes()
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, errProtocol
+	}
+	return data[:size], nil
+}
+
+// 读取一行，超过maxLineLen返回errProtocol
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := r.ReadSlice('\n')
+		if len(line)+len(b) > maxLineLen {
+			return nil, errProtocol
+		}
+		line = append(line, b...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// 写入回复，v的类型决定RESP类型：
+// simpleString为简单字符串，error为错误，int64为整数，[]byte为bulk字符串，nil为空bulk字符串，
+// []interface{}为数组，nilArray为空数组
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch r := v.(type) {
+	case simpleString:
+		w.WriteString("+")
+		w.WriteString(string(r))
+		w.WriteString("\r\n")
+	case error:
+		w.WriteString("-")
+		w.WriteString(r.Error())
+		w.WriteString("\r\n")
+	case int64:
+		w.WriteString(":")
+		w.WriteString(strconv.FormatInt(r, 10))
+		w.WriteString("\r\n")
+	case []byte:
+		w.WriteString("$")
+		w.WriteString(strconv.Itoa(len(r)))
+		w.WriteString("\r\n")
+		w.Write(r)
+		w.WriteString("\r\n")
+	case nil:
+		w.WriteString("$-1\r\n")
+	case nilArray:
+		w.WriteString("*-1\r\n")
+	case []interface{}:
+		w.WriteString("*")
+		w.WriteString(strconv.Itoa(len(r)))
+		w.WriteString("\r\n")
+		for _, e := range r {
+			writeReply(w, e)
+		}
+	default:
+		writeReply(w, fmt.Errorf("ERR unsupported reply type %T", v))
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resp 使用RESP协议将RecycleMap[string, []byte]作为Redis兼容的存储提供服务，
+// 用于在测试中替代Redis，go-redis等客户端可以直接连接
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/xfali/goutils/v2/container/recycleMap"
+)
+
+// ErrServerClosed 服务已关闭
+var ErrServerClosed = errors.New("resp: Server closed ")
+
+// Server RESP服务
+// 与Redis相同，所有客户端的命令串行执行，MULTI/EXEC与SET NX/XX等对其他客户端是原子的；
+// 进程内直接操作RecycleMap不受该保证约束，但是WATCH能够感知这些修改
+type Server struct {
+	m recycleMap.RecycleMap[string, []byte]
+
+	// 串行执行命令
+	cmdLock sync.Mutex
+
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer 创建服务，m需要由调用者关闭
+func NewServer(m recycleMap.RecycleMap[string, []byte]) *Server {
+	return &Server{
+		m:     m,
+		conns: map[net.Conn]struct{}{},
+	}
+}
+
+// Start 在127.0.0.1的随机端口启动服务，通过Addr获得监听地址
+func Start(m recycleMap.RecycleMap[string, []byte]) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer(m)
+	s.listener = l
+	go s.Serve(l)
+	return s, nil
+}
+
+// ListenAndServe 监听addr并提供服务，阻塞直到服务关闭
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在l上提供服务，阻塞直到服务关闭，关闭后返回ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.lock.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Addr 监听地址，服务未启动时返回nil
+func (s *Server) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close 停止监听并关闭所有连接
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	c := &client{server: s}
+	defer func() {
+		c.reset()
+		conn.Close()
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err == errProtocol {
+				writeReply(w, err)
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		reply, quit := c.handle(args)
+		writeReply(w, reply)
+		// 客户端pipeline发送的命令全部处理完再统一发送
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// 连接状态
+type client struct {
+	server *Server
+
+	multi    bool
+	queued   [][][]byte
+	abortErr bool
+	tx       recycleMap.Tx[string, []byte]
+}
+
+func (c *client) reset() {
+	c.multi = false
+	c.queued = nil
+	c.abortErr = false
+	if c.tx != nil {
+		c.tx.Discard()
+		c.tx = nil
+	}
+}
+
+func (c *client) handle(args [][]byte) (reply interface{}, quit bool) {
+	name := strings.ToUpper(string(args[0]))
+	switch name {
+	case "QUIT":
+		return replyOK, true
+	case "MULTI":
+		if c.multi {
+			return errors.New("ERR MULTI calls can not be nested"), false
+		}
+		c.multi = true
+		return replyOK, false
+	case "DISCARD":
+		if !c.multi {
+			return errors.New("ERR DISCARD without MULTI"), false
+		}
+		c.reset()
+		return replyOK, false
+	case "EXEC":
+		if !c.multi {
+			return errors.New("ERR EXEC without MULTI"), false
+		}
+		return c.exec(), false
+	case "WATCH":
+		if c.multi {
+			return errors.New("ERR WATCH inside MULTI is not allowed"), false
+		}
+		if len(args) < 2 {
+			return errArity(name), false
+		}
+		if c.tx == nil {
+			c.tx = c.server.m.Multi()
+		}
+		keys := make([]string, len(args)-1)
+		for i, k := range args[1:] {
+			keys[i] = string(k)
+		}
+		if err := c.tx.Watch(keys...); err != nil {
+			return errors.New("ERR " + err.Error()), false
+		}
+		return replyOK, false
+	case "UNWATCH":
+		if c.tx != nil {
+			c.tx.Discard()
+			c.tx = nil
+		}
+		return replyOK, false
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.abortErr = c.multi
+		return errUnknown(args), false
+	}
+	if !cmd.checkArity(len(args)) {
+		c.abortErr = c.multi
+		return errArity(name), false
+	}
+	if c.multi {
+		c.queued = append(c.queued, args)
+		return replyQueued, false
+	}
+
+	c.server.cmdLock.Lock()
+	defer c.server.cmdLock.Unlock()
+
+	return cmd.handler(c.server.m, args), false
+}
+
+func (c *client) exec() interface{} {
+	defer c.reset()
+
+	if c.abortErr {
+		return errors.New("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	c.server.cmdLock.Lock()
+	defer c.server.cmdLock.Unlock()
+
+	if c.tx != nil {
+		// 没有排队操作的事务只用于检查WATCH的key是否被修改
+		err := c.tx.Exec()
+		c.tx = nil
+		if err == recycleMap.ErrTxAborted {
+			return nilArray{}
+		}
+	}
+	replies := make([]interface{}, len(c.queued))
+	for i, args := range c.queued {
+		name := strings.ToUpper(string(args[0]))
+		replies[i] = commands[name].handler(c.server.m, args)
+	}
+	return replies
+}
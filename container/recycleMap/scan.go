@@ -0,0 +1,213 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultScanCount Scan默认每次检查的key数量
+	DefaultScanCount = 10
+
+	// 分片map的游标高位保存分片序号
+	shardCursorBits = 48
+	shardCursorMask = 1<<shardCursorBits - 1
+)
+
+// 从keys中删除，最后一个key移动到被删除的位置，需要在持有map锁时调用
+// Scan从后往前遍历，被移动的key一定从未遍历的位置移出或者移动到未遍历的位置，
+// 因此Scan期间一直存在的key一定会被返回
+func (dm *defaultRecycleMap[K, V]) removeKey(e *dataEntity[V]) {
+	last := len(dm.keys) - 1
+	if i := e.keyIndex; i != last {
+		moved := dm.keys[last]
+		dm.keys[i] = moved
+		dm.db[moved].keyIndex = i
+	}
+	var zero K
+	dm.keys[last] = zero
+	dm.keys = dm.keys[:last]
+}
+
+// Scan 增量遍历与pattern匹配的key，cursor为0时开始遍历，返回的next为0时遍历结束
+// 每次调用最多检查count个key（小于等于0时使用DefaultScanCount），只在本次调用期间持有锁
+// 与Redis SCAN相同：遍历期间一直存在的key一定会被返回，遍历期间新增或删除的key可能不返回，同一个key可能返回多次
+func (dm *defaultRecycleMap[K, V]) Scan(cursor uint64, pattern K, count int) (next uint64, keys []K) {
+	return dm.ScanFunc(cursor, dm.matcher(pattern), count)
+}
+
+// ScanFunc 与Scan相同，使用match过滤key（为nil时返回所有key），与配置的MatchFunc无关
+func (dm *defaultRecycleMap[K, V]) ScanFunc(cursor uint64, match func(key K) bool, count int) (next uint64, keys []K) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	i := len(dm.keys)
+	if cursor != 0 && cursor < uint64(i) {
+		i = int(cursor)
+	}
+	now := time.Now()
+	for ; i > 0 && count > 0; count-- {
+		i--
+		key := dm.keys[i]
+		if !dm.db[key].expired(now) && (match == nil || match(key)) {
+			keys = append(keys, key)
+		}
+	}
+	return uint64(i), keys
+}
+
+// Scan 按分片顺序增量遍历，游标高16位为分片序号
+func (s *shardedRecycleMap[K, V]) Scan(cursor uint64, pattern K, count int) (next uint64, keys []K) {
+	return s.scan(cursor, func(shard *defaultRecycleMap[K, V], cursor uint64) (uint64, []K) {
+		return shard.Scan(cursor, pattern, count)
+	})
+}
+
+func (s *shardedRecycleMap[K, V]) ScanFunc(cursor uint64, match func(key K) bool, count int) (next uint64, keys []K) {
+	return s.scan(cursor, func(shard *defaultRecycleMap[K, V], cursor uint64) (uint64, []K) {
+		return shard.ScanFunc(cursor, match, count)
+	})
+}
+
+func (s *shardedRecycleMap[K, V]) scan(cursor uint64, f func(shard *defaultRecycleMap[K, V], cursor uint64) (uint64, []K)) (next uint64, keys []K) {
+	shard := cursor >> shardCursorBits
+	if shard >= uint64(len(s.shards)) {
+		return 0, nil
+	}
+	next, keys = f(s.shards[shard], cursor&shardCursorMask)
+	if next != 0 {
+		return shard<<shardCursorBits | next, keys
+	}
+	if shard+1 < uint64(len(s.shards)) {
+		return (shard + 1) << shardCursorBits, keys
+	}
+	return 0, keys
+}
+
+// GlobMatcher 与Redis KEYS、SCAN相同的glob匹配：
+// *匹配任意字符串，?匹配任意单个字符，[abc]、[^abc]、[a-z]匹配字符集合，\转义
+// 空pattern匹配所有key
+func GlobMatcher[K comparable](converter func(v K) string) func(pattern K) func(key K) (match bool) {
+	if converter == nil {
+		converter = func(v K) string {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return func(pattern K) func(key K) (match bool) {
+		p := converter(pattern)
+		if p == "" || p == "*" {
+			return func(key K) (match bool) {
+				return true
+			}
+		}
+		return func(key K) (match bool) {
+			return GlobMatch(p, converter(key))
+		}
+	}
+}
+
+// GlobMatch str是否与glob pattern匹配
+func GlobMatch(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if GlobMatch(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			var ok bool
+			pattern, ok = matchClass(pattern[1:], str[0])
+			if !ok {
+				return false
+			}
+			str = str[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
+
+// 匹配[...]字符集合，pattern为'['之后的部分，返回']'之后的pattern
+func matchClass(pattern string, c byte) (string, bool) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			if pattern[1] == c {
+				match = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				match = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				match = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// 跳过']'
+		pattern = pattern[1:]
+	}
+	return pattern, match != not
+}
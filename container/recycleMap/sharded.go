@@ -0,0 +1,274 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"github.com/xfali/goutils/v2/container/internal/keyhash"
+	"github.com/xfali/goutils/v2/container/purger"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultShardNumber 默认分片数量
+	DefaultShardNumber = 16
+)
+
+type shardedRecycleMap[K comparable, V any] struct {
+	shards   []*defaultRecycleMap[K, V]
+	hasher   func(key K) uint64
+	codec    Codec
+	executor purger.PurgeExecutor
+}
+
+// NewSharded 创建分片RecycleMap，key按hash分布到shards个独立加锁的分片中，shards小于等于0时使用DefaultShardNumber
+// 单key操作只锁住key所在分片，Purge逐个分片清理，每次只持有一个分片的锁
+// Keys、Size、Snapshot逐个分片执行，不是所有分片同一时刻的一致视图；Delete多个key时不保证原子性，需要原子性请使用Multi
+// opts对所有分片生效，其中OptSetLocker被忽略（每个分片使用独立的锁），OptSetPurgeNumberPerTime为每个分片每次清理的数量
+// 订阅的回调可能被不同分片并发调用
+func NewSharded[K comparable, V any](shards int, opts ...Opt[K, V]) RecycleMap[K, V] {
+	if shards <= 0 {
+		shards = DefaultShardNumber
+	}
+	conf := &defaultRecycleMap[K, V]{
+		purgeInterval: DefaultPurgeInterval,
+		purgeNumber:   DefaultPurgeNumberPerTime,
+		matcher:       defaultMatch[K],
+		equal:         defaultEqual[V],
+		codec:         GobCodec,
+		loadTTL:       -1,
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	ret := &shardedRecycleMap[K, V]{
+		shards: make([]*defaultRecycleMap[K, V], shards),
+		hasher: conf.hasher,
+		codec:  conf.codec,
+	}
+	if ret.hasher == nil {
+		ret.hasher = keyhash.Of[K]
+	}
+	for i := range ret.shards {
+		shard := *conf
+		shard.db = map[K]*dataEntity[V]{}
+		shard.lock = &sync.Mutex{}
+		shard.loads = newLoadState[K, V]()
+		ret.shards[i] = &shard
+	}
+	conf.startPurge(ret)
+	if !conf.manualPurge {
+		ret.executor = conf.executor
+	}
+
+	return ret
+}
+
+func (s *shardedRecycleMap[K, V]) shardOf(key K) *defaultRecycleMap[K, V] {
+	return s.shards[s.hasher(key)%uint64(len(s.shards))]
+}
+
+// 按分片顺序加锁，避免多个事务之间死锁
+func (s *shardedRecycleMap[K, V]) lockAll() {
+	for _, shard := range s.shards {
+		shard.lock.Lock()
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) unlockAll() {
+	for i := len(s.shards) - 1; i >= 0; i-- {
+		s.shards[i].lock.Unlock()
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) Set(key K, value V, expireIn time.Duration) error {
+	return s.shardOf(key).Set(key, value, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) Get(key K) V {
+	return s.shardOf(key).Get(key)
+}
+
+func (s *shardedRecycleMap[K, V]) SetNX(key K, value V, expireIn time.Duration) (bool, error) {
+	return s.shardOf(key).SetNX(key, value, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) GetSet(key K, value V, expireIn time.Duration) (V, bool, error) {
+	return s.shardOf(key).GetSet(key, value, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) GetAndDelete(key K) (V, bool) {
+	return s.shardOf(key).GetAndDelete(key)
+}
+
+func (s *shardedRecycleMap[K, V]) CompareAndSwap(key K, old, new V, expireIn time.Duration) (bool, error) {
+	return s.shardOf(key).CompareAndSwap(key, old, new, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) Update(key K, fn func(old V, exists bool) V, expireIn time.Duration) (V, error) {
+	return s.shardOf(key).Update(key, fn, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) Lookup(key K) (V, bool) {
+	return s.shardOf(key).Lookup(key)
+}
+
+func (s *shardedRecycleMap[K, V]) GetWithTTL(key K) (V, time.Duration, bool) {
+	return s.shardOf(key).GetWithTTL(key)
+}
+
+func (s *shardedRecycleMap[K, V]) MGet(keys ...K) map[K]V {
+	ret := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if v, ok := s.shardOf(key).Lookup(key); ok {
+			ret[key] = v
+		}
+	}
+	return ret
+}
+
+// MSet 批量设置，会锁住所有分片以保证原子性
+func (s *shardedRecycleMap[K, V]) MSet(entries ...Entry[K, V]) error {
+	s.lockAll()
+	defer s.unlockAll()
+
+	var err error
+	for _, e := range entries {
+		if e2 := s.shardOf(e.Key).innerSet(e.Key, e.Value, e.ExpireIn); e2 != nil && err == nil {
+			err = e2
+		}
+	}
+	return err
+}
+
+// Range 逐个分片遍历，每个分片遍历的是该分片的快照
+func (s *shardedRecycleMap[K, V]) Range(f func(key K, value V, ttl time.Duration) bool) {
+	for _, shard := range s.shards {
+		stop := false
+		shard.Range(func(key K, value V, ttl time.Duration) bool {
+			if !f(key, value, ttl) {
+				stop = true
+				return false
+			}
+			return true
+		})
+		if stop {
+			return
+		}
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) Keys(pattern K) []K {
+	var ret []K
+	for _, shard := range s.shards {
+		ret = append(ret, shard.Keys(pattern)...)
+	}
+	return ret
+}
+
+func (s *shardedRecycleMap[K, V]) Delete(keys ...K) int64 {
+	var total int64 = 0
+	for _, key := range keys {
+		total += s.shardOf(key).Delete(key)
+	}
+	return total
+}
+
+func (s *shardedRecycleMap[K, V]) SetExpire(key K, expireIn time.Duration) bool {
+	return s.shardOf(key).SetExpire(key, expireIn)
+}
+
+func (s *shardedRecycleMap[K, V]) TTL(key K) time.Duration {
+	return s.shardOf(key).TTL(key)
+}
+
+func (s *shardedRecycleMap[K, V]) Size() int64 {
+	var size int64 = 0
+	for _, shard := range s.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+func (s *shardedRecycleMap[K, V]) Purge() {
+	for _, shard := range s.shards {
+		shard.Purge()
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) Snapshot(w io.Writer) error {
+	for _, shard := range s.shards {
+		if err := shard.Snapshot(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shardedRecycleMap[K, V]) Restore(r io.Reader) error {
+	return restore(r, s.codec, func(rec *record[K, V], now time.Time) {
+		s.shardOf(rec.Key).apply(rec, now)
+	})
+}
+
+// Multi 开启事务，Watch、Exec与Discard会锁住所有分片
+func (s *shardedRecycleMap[K, V]) Multi() Tx[K, V] {
+	return &defaultTx[K, V]{store: s}
+}
+
+func (s *shardedRecycleMap[K, V]) Subscribe(pattern K, types EventType, listener EventListener[K, V]) (cancel func()) {
+	cancels := make([]func(), len(s.shards))
+	for i, shard := range s.shards {
+		cancels[i] = shard.Subscribe(pattern, types, listener)
+	}
+	return func() {
+		for _, c := range cancels {
+			c()
+		}
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) SubscribeChan(pattern K, types EventType, ch chan<- Event[K, V]) (cancel func()) {
+	cancels := make([]func(), len(s.shards))
+	for i, shard := range s.shards {
+		cancels[i] = shard.SubscribeChan(pattern, types, ch)
+	}
+	return func() {
+		for _, c := range cancels {
+			c()
+		}
+	}
+}
+
+func (s *shardedRecycleMap[K, V]) Close() error {
+	if s.executor != nil {
+		if r, ok := s.executor.(purger.PurgerRemover); ok {
+			r.RemovePurger(s)
+		}
+	}
+	s.Purge()
+	return nil
+}
+
+// OptSetShardHasher 配置分片map的key hash函数，仅对NewSharded创建的map生效
+func OptSetShardHasher[K comparable, V any](hasher func(key K) uint64) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		recycleMap.hasher = hasher
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrTxAborted 被监视的key在Watch之后被修改，事务未执行
+	ErrTxAborted = errors.New("RecycleMap transaction aborted: watched key has been modified ")
+	// ErrTxDone 事务已经执行或者已经放弃
+	ErrTxDone = errors.New("RecycleMap transaction has already been executed or discarded ")
+)
+
+// Tx 事务，与Redis MULTI/EXEC模型一致：
+// 操作先排队，Exec时在map锁内原子执行；Watch的key在Exec之前被修改（包括过期）则放弃执行并返回ErrTxAborted。
+// Tx本身不是线程安全的，只能在一个协程中使用
+type Tx[K comparable, V any] interface {
+	// Watch 监视key，需要在读取key的值之前调用
+	Watch(keys ...K) error
+
+	// Set 排队设置一个值，含过期时间
+	Set(key K, value V, expireIn time.Duration) Tx[K, V]
+
+	// Delete 排队删除key
+	Delete(keys ...K) Tx[K, V]
+
+	// SetExpire 排队设置key过期时间
+	SetExpire(key K, expireIn time.Duration) Tx[K, V]
+
+	// Exec 原子执行所有排队的操作并取消监视
+	Exec() error
+
+	// Discard 放弃所有排队的操作并取消监视
+	Discard() error
+}
+
+// 事务所操作的存储，分片map中一个事务可能涉及多个分片
+type txStore[K comparable, V any] interface {
+	// 锁住事务涉及的所有数据
+	lockAll()
+	unlockAll()
+	// key所在的map
+	shardOf(key K) *defaultRecycleMap[K, V]
+}
+
+type defaultTx[K comparable, V any] struct {
+	store txStore[K, V]
+	ops   []func() error
+	keys  []K
+	// 监视时key是否存在且未过期，与keys一一对应
+	live []bool
+	// 分片map中监视的key可能在不同分片，由持有不同分片锁的协程并发标记，使用原子操作
+	dirty int32
+	done  bool
+}
+
+// Multi 开启事务
+func (dm *defaultRecycleMap[K, V]) Multi() Tx[K, V] {
+	return &defaultTx[K, V]{store: dm}
+}
+
+func (dm *defaultRecycleMap[K, V]) lockAll() {
+	dm.lock.Lock()
+}
+
+func (dm *defaultRecycleMap[K, V]) unlockAll() {
+	dm.lock.Unlock()
+}
+
+func (dm *defaultRecycleMap[K, V]) shardOf(key K) *defaultRecycleMap[K, V] {
+	return dm
+}
+
+// 标记监视key的事务为已修改，并使key正在执行的GetOrLoad加载失效，需要在持有map锁时调用
+func (dm *defaultRecycleMap[K, V]) touch(key K) {
+	dm.forgetLoad(key)
+	if len(dm.watched) == 0 {
+		return
+	}
+	for tx := range dm.watched[key] {
+		atomic.StoreInt32(&tx.dirty, 1)
+	}
+}
+
+func (tx *defaultTx[K, V]) Watch(keys ...K) error {
+	tx.store.lockAll()
+	defer tx.store.unlockAll()
+
+	if tx.done {
+		return ErrTxDone
+	}
+	now := time.Now()
+	for _, key := range keys {
+		dm := tx.store.shardOf(key)
+		if dm.watched == nil {
+			dm.watched = map[K]map[*defaultTx[K, V]]struct{}{}
+		}
+		txs, ok := dm.watched[key]
+		if !ok {
+			txs = map[*defaultTx[K, V]]struct{}{}
+			dm.watched[key] = txs
+		}
+		if _, ok := txs[tx]; !ok {
+			txs[tx] = struct{}{}
+			e, ok := dm.db[key]
+			tx.keys = append(tx.keys, key)
+			tx.live = append(tx.live, ok && !e.expired(now))
+		}
+	}
+	return nil
+}
+
+func (tx *defaultTx[K, V]) Set(key K, value V, expireIn time.Duration) Tx[K, V] {
+	tx.ops = append(tx.ops, func() error {
+		return tx.store.shardOf(key).innerSet(key, value, expireIn)
+	})
+	return tx
+}
+
+func (tx *defaultTx[K, V]) Delete(keys ...K) Tx[K, V] {
+	tx.ops = append(tx.ops, func() error {
+		for _, key := range keys {
+			tx.store.shardOf(key).innerDeleteKeys(key)
+		}
+		return nil
+	})
+	return tx
+}
+
+func (tx *defaultTx[K, V]) SetExpire(key K, expireIn time.Duration) Tx[K, V] {
+	tx.ops = append(tx.ops, func() error {
+		tx.store.shardOf(key).innerSetExpire(key, expireIn)
+		return nil
+	})
+	return tx
+}
+
+func (tx *defaultTx[K, V]) Exec() error {
+	tx.store.lockAll()
+	defer tx.store.unlockAll()
+
+	if tx.done {
+		return ErrTxDone
+	}
+	ops := tx.ops
+	aborted := atomic.LoadInt32(&tx.dirty) == 1 || tx.watchedExpired()
+	tx.finish()
+	if aborted {
+		return ErrTxAborted
+	}
+	var err error
+	for _, op := range ops {
+		if e := op(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (tx *defaultTx[K, V]) Discard() error {
+	tx.store.lockAll()
+	defer tx.store.unlockAll()
+
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.finish()
+	return nil
+}
+
+// 监视时存在的key已过期但尚未被删除（没有标记事务为已修改）时同样视为被修改，需要在持有锁时调用
+func (tx *defaultTx[K, V]) watchedExpired() bool {
+	now := time.Now()
+	for i, key := range tx.keys {
+		if !tx.live[i] {
+			continue
+		}
+		if e, ok := tx.store.shardOf(key).db[key]; !ok || e.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取消监视，需要在持有锁时调用
+func (tx *defaultTx[K, V]) finish() {
+	tx.done = true
+	for _, key := range tx.keys {
+		dm := tx.store.shardOf(key)
+		if txs, ok := dm.watched[key]; ok {
+			delete(txs, tx)
+			if len(txs) == 0 {
+				delete(dm.watched, key)
+			}
+		}
+	}
+	tx.keys = nil
+	tx.live = nil
+	tx.ops = nil
+}
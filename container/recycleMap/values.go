@@ -0,0 +1,142 @@
+/*
+ * Copyright 2022 Xiongfa Li.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recycleMap
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// KeepTTL 作为expireIn传入时保留key原来的过期时间，key不存在时永不过期
+const KeepTTL time.Duration = math.MinInt64
+
+// Number 支持Incr、Decr的数值类型
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// 以下操作均在map锁内原子执行，expireIn含义与Set相同
+
+// SetNX key不存在时设置值，返回是否设置成功
+func (dm *defaultRecycleMap[K, V]) SetNX(key K, value V, expireIn time.Duration) (bool, error) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	if _, ok := dm.lookup(key, time.Now()); ok {
+		return false, nil
+	}
+	return true, dm.innerSet(key, value, expireIn)
+}
+
+// GetSet 设置新值并返回旧值
+func (dm *defaultRecycleMap[K, V]) GetSet(key K, value V, expireIn time.Duration) (old V, exists bool, err error) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	if v, ok := dm.lookup(key, time.Now()); ok {
+		old, exists = v.value, true
+	}
+	return old, exists, dm.innerSet(key, value, expireIn)
+}
+
+// GetAndDelete 获取并删除key
+func (dm *defaultRecycleMap[K, V]) GetAndDelete(key K) (V, bool) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	v, ok := dm.lookup(key, time.Now())
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	dm.innerDeleteKeys(key)
+	return v.value, true
+}
+
+// CompareAndSwap key存在且当前值与old相等（使用OptSetEqualFunc配置的比较函数）时设置为new
+func (dm *defaultRecycleMap[K, V]) CompareAndSwap(key K, old, new V, expireIn time.Duration) (bool, error) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	v, ok := dm.lookup(key, time.Now())
+	if !ok || !dm.equal(v.value, old) {
+		return false, nil
+	}
+	return true, dm.innerSet(key, new, expireIn)
+}
+
+// Update 根据当前值计算并设置新值，返回新值
+// 注意：fn在map锁内调用，不能在fn中调用该map的方法
+func (dm *defaultRecycleMap[K, V]) Update(key K, fn func(old V, exists bool) V, expireIn time.Duration) (V, error) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+
+	var old V
+	v, exists := dm.lookup(key, time.Now())
+	if exists {
+		old = v.value
+	}
+	value := fn(old, exists)
+	return value, dm.innerSet(key, value, expireIn)
+}
+
+// Incr 将key的值原子增加delta并返回新值，key不存在时从0开始，保留key原来的过期时间
+func Incr[K comparable, V Number](m RecycleMap[K, V], key K, delta V) (V, error) {
+	return m.Update(key, func(old V, exists bool) V {
+		return old + delta
+	}, KeepTTL)
+}
+
+// Decr 将key的值原子减少delta并返回新值，key不存在时从0开始，保留key原来的过期时间
+func Decr[K comparable, V Number](m RecycleMap[K, V], key K, delta V) (V, error) {
+	return m.Update(key, func(old V, exists bool) V {
+		return old - delta
+	}, KeepTTL)
+}
+
+// Append 将value追加到key的值末尾并返回追加后的长度，key不存在时等同于设置value，保留key原来的过期时间
+// 值为[]byte时总是创建新的slice，不会修改原来的值
+func Append[K comparable, V ~string | ~[]byte](m RecycleMap[K, V], key K, value V) (int, error) {
+	ret, err := m.Update(key, func(old V, exists bool) V {
+		buf := make([]byte, 0, len(old)+len(value))
+		buf = append(buf, old...)
+		buf = append(buf, value...)
+		return V(buf)
+	}, KeepTTL)
+	return len(ret), err
+}
+
+// OptSetEqualFunc 配置CompareAndSwap使用的比较函数，默认使用==，值不可比较（如slice、map）时使用reflect.DeepEqual
+func OptSetEqualFunc[K comparable, V any](equal func(a, b V) bool) Opt[K, V] {
+	return func(recycleMap *defaultRecycleMap[K, V]) {
+		if equal != nil {
+			recycleMap.equal = equal
+		}
+	}
+}
+
+func defaultEqual[V any](a, b V) (equal bool) {
+	defer func() {
+		if o := recover(); o != nil {
+			equal = reflect.DeepEqual(a, b)
+		}
+	}()
+	return interface{}(a) == interface{}(b)
+}
@@ -0,0 +1,195 @@
+/**
+ * Copyright (C) 2018, Xiongfa Li.
+ * All right reserved.
+ * @author xiongfa.li
+ * @version V1.0
+ * Description:
+ */
+
+package container
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const cacheLineSize = 64
+
+type cacheLinePad [cacheLineSize]byte
+
+// RingQueue 有界无锁多生产者多消费者队列（Dmitry Vyukov的MPMC环形队列）
+// 每个槽位保存一个序号，生产者与消费者只通过CAS争用head、tail，入队出队不分配内存
+type RingQueue[T any] struct {
+	_      cacheLinePad
+	head   uint64
+	_      cacheLinePad
+	tail   uint64
+	_      cacheLinePad
+	closed int32
+	mask   uint64
+	cells  []ringCell[T]
+}
+
+type ringCell[T any] struct {
+	// 等于位置时可写，等于位置+1时可读
+	seq   uint64
+	value T
+}
+
+// NewRingQueue 创建容量为capacity向上取整为2的幂的无锁队列，capacity最小为2
+func NewRingQueue[T any](capacity int) *RingQueue[T] {
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+	q := &RingQueue[T]{
+		mask:  uint64(size - 1),
+		cells: make([]ringCell[T], size),
+	}
+	for i := range q.cells {
+		q.cells[i].seq = uint64(i)
+	}
+	return q
+}
+
+// TryPush 不阻塞地入队，队列满或者已关闭时返回false
+func (q *RingQueue[T]) TryPush(v T) bool {
+	if atomic.LoadInt32(&q.closed) == 1 {
+		return false
+	}
+	pos := atomic.LoadUint64(&q.tail)
+	for {
+		cell := &q.cells[pos&q.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.tail, pos, pos+1) {
+				cell.value = v
+				atomic.StoreUint64(&cell.seq, pos+1)
+				return true
+			}
+			pos = atomic.LoadUint64(&q.tail)
+		case dif < 0:
+			// 槽位还未被消费，队列满
+			return false
+		default:
+			// 其他生产者已经占用该位置
+			pos = atomic.LoadUint64(&q.tail)
+		}
+	}
+}
+
+// TryPop 不阻塞地出队，队列为空时ok为false
+func (q *RingQueue[T]) TryPop() (v T, ok bool) {
+	pos := atomic.LoadUint64(&q.head)
+	for {
+		cell := &q.cells[pos&q.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&q.head, pos, pos+1) {
+				v = cell.value
+				var zero T
+				cell.value = zero
+				atomic.StoreUint64(&cell.seq, pos+q.mask+1)
+				return v, true
+			}
+			pos = atomic.LoadUint64(&q.head)
+		case dif < 0:
+			// 槽位还未被写入，队列为空
+			return v, false
+		default:
+			// 其他消费者已经取走该位置
+			pos = atomic.LoadUint64(&q.head)
+		}
+	}
+}
+
+// Push 入队，队列满时自旋等待，队列关闭后数据被丢弃
+func (q *RingQueue[T]) Push(v T) {
+	q.PushContext(context.Background(), v)
+}
+
+// PushContext 入队，队列满时自旋等待直到有空位、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+func (q *RingQueue[T]) PushContext(ctx context.Context, v T) error {
+	for i := 0; ; i++ {
+		if q.TryPush(v) {
+			return nil
+		}
+		if atomic.LoadInt32(&q.closed) == 1 {
+			return ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		backoff(i)
+	}
+}
+
+// Pop 出队，队列为空时自旋等待，队列关闭并且为空时返回零值
+func (q *RingQueue[T]) Pop() T {
+	v, _ := q.PopContext(context.Background())
+	return v
+}
+
+// PopContext 出队，队列为空时自旋等待直到有数据、ctx结束（返回ctx.Err()）或者队列关闭（返回ErrClosed）
+// 队列关闭后仍然可以取出剩余的数据，取完后返回ErrClosed
+func (q *RingQueue[T]) PopContext(ctx context.Context) (T, error) {
+	for i := 0; ; i++ {
+		if v, ok := q.TryPop(); ok {
+			return v, nil
+		}
+		if atomic.LoadInt32(&q.closed) == 1 {
+			// 关闭前最后入队的数据可能刚写入
+			if v, ok := q.TryPop(); ok {
+				return v, nil
+			}
+			var zero T
+			return zero, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+		backoff(i)
+	}
+}
+
+// Len 队列中元素个数，并发修改时为近似值
+func (q *RingQueue[T]) Len() int {
+	head := atomic.LoadUint64(&q.head)
+	tail := atomic.LoadUint64(&q.tail)
+	if tail < head {
+		return 0
+	}
+	return int(tail - head)
+}
+
+// Cap 队列容量
+func (q *RingQueue[T]) Cap() int {
+	return len(q.cells)
+}
+
+// Close 关闭队列，之后入队返回ErrClosed，出队取完剩余数据后返回ErrClosed
+// 与Close并发的入队可能成功，但是消费者可能已经返回ErrClosed，需要保证数据不丢失时应在生产者全部结束后再关闭
+func (q *RingQueue[T]) Close() error {
+	atomic.StoreInt32(&q.closed, 1)
+	return nil
+}
+
+// 等待策略：先自旋，再让出调度，最后休眠（最长1ms）
+func backoff(i int) {
+	switch {
+	case i < 16:
+	case i < 32:
+		runtime.Gosched()
+	default:
+		d := time.Microsecond << uint(i-32)
+		if d > time.Millisecond || d <= 0 {
+			d = time.Millisecond
+		}
+		time.Sleep(d)
+	}
+}
@@ -5,6 +5,41 @@
 
 package skiplist
 
+// Ordered 可以使用 < <= >= > 运算符比较的类型约束，与go1.21的cmp.Ordered一致
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 |
+		~string
+}
+
+// CompareOrdered 比较有序类型，a小于b返回-1，相等返回0，大于返回1
+// 浮点数NaN视为小于任何非NaN值，并且与NaN相等
+func CompareOrdered[K Ordered](a, b K) int {
+	aNaN := isNaN(a)
+	bNaN := isNaN(b)
+	if aNaN {
+		if bNaN {
+			return 0
+		}
+		return -1
+	}
+	if bNaN {
+		return 1
+	}
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func isNaN[K Ordered](x K) bool {
+	return x != x
+}
+
 func CompareInt(a, b interface{}) int {
 	x1 := 0
 	if a != nil {
@@ -0,0 +1,407 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package skiplist
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// 并发跳表节点，next与value通过原子操作读写，修改链接关系时需要持有节点锁
+type concurrentNode[K, V any] struct {
+	key   K
+	value unsafe.Pointer
+	next  []unsafe.Pointer
+
+	lock        sync.Mutex
+	marked      int32
+	fullyLinked int32
+}
+
+func newConcurrentNode[K, V any](lvl int, key K, value V) *concurrentNode[K, V] {
+	return &concurrentNode[K, V]{
+		key:   key,
+		value: unsafe.Pointer(&value),
+		next:  make([]unsafe.Pointer, lvl),
+	}
+}
+
+func (n *concurrentNode[K, V]) loadNext(i int) *concurrentNode[K, V] {
+	return (*concurrentNode[K, V])(atomic.LoadPointer(&n.next[i]))
+}
+
+func (n *concurrentNode[K, V]) storeNext(i int, next *concurrentNode[K, V]) {
+	atomic.StorePointer(&n.next[i], unsafe.Pointer(next))
+}
+
+func (n *concurrentNode[K, V]) loadValue() V {
+	return *(*V)(atomic.LoadPointer(&n.value))
+}
+
+func (n *concurrentNode[K, V]) storeValue(v V) {
+	atomic.StorePointer(&n.value, unsafe.Pointer(&v))
+}
+
+func (n *concurrentNode[K, V]) isMarked() bool {
+	return atomic.LoadInt32(&n.marked) == 1
+}
+
+func (n *concurrentNode[K, V]) isFullyLinked() bool {
+	return atomic.LoadInt32(&n.fullyLinked) == 1
+}
+
+// 节点在跳表中的判定条件：已完成所有层的链接并且未被标记删除
+func (n *concurrentNode[K, V]) alive() bool {
+	return n.isFullyLinked() && !n.isMarked()
+}
+
+type ConcurrentOpt[K, V any] func(*ConcurrentSkipList[K, V])
+
+// ConcurrentSkipList 并发安全的跳表
+// 采用lazy skip list算法：查找无锁，Set和Delete只锁定受影响的前驱节点。
+// 迭代器为弱一致性，迭代过程中允许并发修改，已被删除的节点仍然可以继续向后迭代。
+type ConcurrentSkipList[K, V any] struct {
+	maxLv int
+	p     float32
+
+	len    int64
+	header *concurrentNode[K, V]
+	keyCmp CompareOf[K]
+}
+
+type ConcurrentIterator[K, V any] struct {
+	n *concurrentNode[K, V]
+}
+
+// NewConcurrent 创建键为有序类型的并发跳表，默认使用CompareOrdered比较键
+func NewConcurrent[K Ordered, V any](opts ...ConcurrentOpt[K, V]) *ConcurrentSkipList[K, V] {
+	return newConcurrentSkipList[K, V](CompareOrdered[K], opts...)
+}
+
+// NewConcurrentFunc 使用自定义比较函数创建并发跳表
+func NewConcurrentFunc[K, V any](cmp CompareOf[K], opts ...ConcurrentOpt[K, V]) *ConcurrentSkipList[K, V] {
+	return newConcurrentSkipList[K, V](cmp, opts...)
+}
+
+func newConcurrentSkipList[K, V any](cmp CompareOf[K], opts ...ConcurrentOpt[K, V]) *ConcurrentSkipList[K, V] {
+	ret := &ConcurrentSkipList[K, V]{
+		maxLv:  SKIPLIST_MAX_LEVEL,
+		p:      SKIPLIST_P,
+		keyCmp: cmp,
+	}
+	for _, v := range opts {
+		v(ret)
+	}
+
+	if ret.keyCmp == nil {
+		panic("keyCmp is nil!")
+	}
+	if ret.maxLv < 1 {
+		ret.maxLv = 1
+	}
+	var (
+		k K
+		v V
+	)
+	ret.header = newConcurrentNode(ret.maxLv, k, v)
+	ret.header.fullyLinked = 1
+	return ret
+}
+
+// SetConcurrentKeyCompareFunc 配置并发跳表的键比较函数
+func SetConcurrentKeyCompareFunc[K, V any](cmp CompareOf[K]) ConcurrentOpt[K, V] {
+	return func(list *ConcurrentSkipList[K, V]) {
+		list.keyCmp = cmp
+	}
+}
+
+// SetConcurrentMaxLevel 配置并发跳表的最大层数
+func SetConcurrentMaxLevel[K, V any](maxLv int) ConcurrentOpt[K, V] {
+	return func(list *ConcurrentSkipList[K, V]) {
+		list.maxLv = maxLv
+	}
+}
+
+// SetConcurrentP 配置并发跳表节点晋升上一层的概率
+func SetConcurrentP[K, V any](p float32) ConcurrentOpt[K, V] {
+	return func(list *ConcurrentSkipList[K, V]) {
+		list.p = p
+	}
+}
+
+func (list *ConcurrentSkipList[K, V]) randomLevel() int {
+	level := 1
+	f := int(0xFFFF * list.p)
+	for ((rand.Int() & 0xFFFF) < f) && (list.maxLv > level) {
+		level += 1
+	}
+
+	return level
+}
+
+// 查找key，记录每一层的前驱与后继节点
+// Return：找到key的最高层，不存在返回-1
+func (list *ConcurrentSkipList[K, V]) find(key K, preds, succs []*concurrentNode[K, V]) int {
+	found := -1
+	pred := list.header
+	for i := list.maxLv - 1; i >= 0; i-- {
+		curr := pred.loadNext(i)
+		for curr != nil && list.keyCmp(curr.key, key) < 0 {
+			pred = curr
+			curr = pred.loadNext(i)
+		}
+		if found == -1 && curr != nil && list.keyCmp(curr.key, key) == 0 {
+			found = i
+		}
+		preds[i] = pred
+		succs[i] = curr
+	}
+	return found
+}
+
+func unlockPreds[K, V any](preds []*concurrentNode[K, V], highestLocked int) {
+	var prev *concurrentNode[K, V]
+	for i := 0; i <= highestLocked; i++ {
+		if preds[i] != prev {
+			preds[i].lock.Unlock()
+			prev = preds[i]
+		}
+	}
+}
+
+func (list *ConcurrentSkipList[K, V]) Get(searchKey K) V {
+	v, _ := list.Lookup(searchKey)
+	return v
+}
+
+// Lookup 根据key获取value，key不存在时ok返回false
+func (list *ConcurrentSkipList[K, V]) Lookup(searchKey K) (value V, ok bool) {
+	x := list.findGreaterOrEqual(searchKey)
+	if x != nil && list.keyCmp(x.key, searchKey) == 0 {
+		return x.loadValue(), true
+	}
+	return value, false
+}
+
+// 查找第一个key大于等于searchKey的有效节点
+func (list *ConcurrentSkipList[K, V]) findGreaterOrEqual(searchKey K) *concurrentNode[K, V] {
+	pred := list.header
+	var curr *concurrentNode[K, V]
+	for i := list.maxLv - 1; i >= 0; i-- {
+		curr = pred.loadNext(i)
+		for curr != nil && list.keyCmp(curr.key, searchKey) < 0 {
+			pred = curr
+			curr = pred.loadNext(i)
+		}
+	}
+	for curr != nil && !curr.alive() {
+		curr = curr.loadNext(0)
+	}
+	return curr
+}
+
+func (list *ConcurrentSkipList[K, V]) Set(searchKey K, newValue V) {
+	preds := make([]*concurrentNode[K, V], list.maxLv)
+	succs := make([]*concurrentNode[K, V], list.maxLv)
+	topLevel := list.randomLevel()
+	for {
+		found := list.find(searchKey, preds, succs)
+		if found != -1 {
+			x := succs[found]
+			if !x.isMarked() {
+				// 等待其他协程完成节点的链接
+				for !x.isFullyLinked() {
+					runtime.Gosched()
+				}
+				x.storeValue(newValue)
+				return
+			}
+			// 节点正在被删除，重试
+			continue
+		}
+
+		highestLocked := -1
+		valid := true
+		var prev *concurrentNode[K, V]
+		for i := 0; valid && i < topLevel; i++ {
+			pred, succ := preds[i], succs[i]
+			if pred != prev {
+				pred.lock.Lock()
+				highestLocked = i
+				prev = pred
+			}
+			valid = !pred.isMarked() && (succ == nil || !succ.isMarked()) && pred.loadNext(i) == succ
+		}
+		if !valid {
+			unlockPreds(preds, highestLocked)
+			continue
+		}
+
+		x := newConcurrentNode(topLevel, searchKey, newValue)
+		for i := 0; i < topLevel; i++ {
+			x.next[i] = unsafe.Pointer(succs[i])
+		}
+		for i := 0; i < topLevel; i++ {
+			preds[i].storeNext(i, x)
+		}
+		atomic.StoreInt32(&x.fullyLinked, 1)
+		unlockPreds(preds, highestLocked)
+		atomic.AddInt64(&list.len, 1)
+		return
+	}
+}
+
+func (list *ConcurrentSkipList[K, V]) Delete(searchKey K) bool {
+	preds := make([]*concurrentNode[K, V], list.maxLv)
+	succs := make([]*concurrentNode[K, V], list.maxLv)
+	var victim *concurrentNode[K, V]
+	marked := false
+	topLevel := -1
+	for {
+		found := list.find(searchKey, preds, succs)
+		if !marked {
+			if found == -1 {
+				return false
+			}
+			victim = succs[found]
+			if !victim.isFullyLinked() || victim.isMarked() || len(victim.next)-1 != found {
+				return false
+			}
+			topLevel = len(victim.next)
+			victim.lock.Lock()
+			if victim.isMarked() {
+				victim.lock.Unlock()
+				return false
+			}
+			atomic.StoreInt32(&victim.marked, 1)
+			marked = true
+		}
+
+		highestLocked := -1
+		valid := true
+		var prev *concurrentNode[K, V]
+		for i := 0; valid && i < topLevel; i++ {
+			pred := preds[i]
+			if pred != prev {
+				pred.lock.Lock()
+				highestLocked = i
+				prev = pred
+			}
+			valid = !pred.isMarked() && pred.loadNext(i) == victim
+		}
+		if !valid {
+			unlockPreds(preds, highestLocked)
+			continue
+		}
+
+		for i := topLevel - 1; i >= 0; i-- {
+			preds[i].storeNext(i, victim.loadNext(i))
+		}
+		victim.lock.Unlock()
+		unlockPreds(preds, highestLocked)
+		atomic.AddInt64(&list.len, -1)
+		return true
+	}
+}
+
+func (list *ConcurrentSkipList[K, V]) Len() int {
+	return int(atomic.LoadInt64(&list.len))
+}
+
+func (list *ConcurrentSkipList[K, V]) Values(size int) []V {
+	var ret []V
+	if size > 0 {
+		ret = make([]V, 0, size)
+	}
+	for x := list.header.loadNext(0); x != nil; x = x.loadNext(0) {
+		if size >= 0 && len(ret) >= size {
+			break
+		}
+		if x.alive() {
+			ret = append(ret, x.loadValue())
+		}
+	}
+	return ret
+}
+
+func (list *ConcurrentSkipList[K, V]) First() *ConcurrentIterator[K, V] {
+	x := list.header.loadNext(0)
+	for x != nil && !x.alive() {
+		x = x.loadNext(0)
+	}
+	if x == nil {
+		return nil
+	}
+	return &ConcurrentIterator[K, V]{n: x}
+}
+
+func (list *ConcurrentSkipList[K, V]) Last() *ConcurrentIterator[K, V] {
+	for {
+		x := list.header
+		for i := list.maxLv - 1; i >= 0; i-- {
+			for next := x.loadNext(i); next != nil; next = x.loadNext(i) {
+				x = next
+			}
+		}
+		if x == list.header {
+			return nil
+		}
+		if x.alive() {
+			return &ConcurrentIterator[K, V]{n: x}
+		}
+	}
+}
+
+func (list *ConcurrentSkipList[K, V]) FirstNear(searchKey K) *ConcurrentIterator[K, V] {
+	x := list.findGreaterOrEqual(searchKey)
+	if x == nil {
+		return nil
+	}
+	return &ConcurrentIterator[K, V]{n: x}
+}
+
+// Next 移动到下一个有效元素，迭代过程中当前元素被删除不影响继续迭代
+func (it *ConcurrentIterator[K, V]) Next() *ConcurrentIterator[K, V] {
+	if it.n == nil {
+		return nil
+	}
+	x := it.n.loadNext(0)
+	for x != nil && !x.alive() {
+		x = x.loadNext(0)
+	}
+	it.n = x
+	if x == nil {
+		return nil
+	}
+	return it
+}
+
+func (it *ConcurrentIterator[K, V]) Key() (key K) {
+	if it.n == nil {
+		return key
+	}
+
+	return it.n.key
+}
+
+func (it *ConcurrentIterator[K, V]) Value() (value V) {
+	if it.n == nil {
+		return value
+	}
+
+	return it.n.loadValue()
+}
+
+func (it *ConcurrentIterator[K, V]) KeyValue() (key K, value V) {
+	if it.n == nil {
+		return key, value
+	}
+
+	return it.n.key, it.n.loadValue()
+}
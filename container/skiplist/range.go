@@ -0,0 +1,174 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package skiplist
+
+// RangeFlag 范围查询的边界标志
+type RangeFlag int
+
+const (
+	// RangeInclude 包含from和to: [from, to]
+	RangeInclude RangeFlag = 0
+	// RangeExcludeFrom 不包含from: (from, to]
+	RangeExcludeFrom RangeFlag = 1
+	// RangeExcludeTo 不包含to: [from, to)
+	RangeExcludeTo RangeFlag = 1 << 1
+	// RangeExclude 不包含from和to: (from, to)
+	RangeExclude = RangeExcludeFrom | RangeExcludeTo
+)
+
+func (list *SkipListOf[K, V]) afterFrom(key, from K, flag RangeFlag) bool {
+	c := list.keyCmp(key, from)
+	return c > 0 || (c == 0 && flag&RangeExcludeFrom == 0)
+}
+
+func (list *SkipListOf[K, V]) beforeTo(key, to K, flag RangeFlag) bool {
+	c := list.keyCmp(key, to)
+	return c < 0 || (c == 0 && flag&RangeExcludeTo == 0)
+}
+
+// 查找范围内的第一个节点，update不为nil时记录每一层的前驱节点
+func (list *SkipListOf[K, V]) firstInRange(from, to K, flag RangeFlag, update []*NodeOf[K, V]) *NodeOf[K, V] {
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !list.afterFrom(x.level[i].forward.key, from, flag) {
+			x = x.level[i].forward
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !list.beforeTo(x.key, to, flag) {
+		return nil
+	}
+	return x
+}
+
+// 查找范围内的最后一个节点
+func (list *SkipListOf[K, V]) lastInRange(from, to K, flag RangeFlag) *NodeOf[K, V] {
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && list.beforeTo(x.level[i].forward.key, to, flag) {
+			x = x.level[i].forward
+		}
+	}
+	if x == list.header || !list.afterFrom(x.key, from, flag) {
+		return nil
+	}
+	return x
+}
+
+// Range 按key从小到大轮询[from, to]范围内的元素，边界是否包含由flag决定
+// Param：f 接受轮询的函数，返回true继续轮询，返回false终止轮询
+func (list *SkipListOf[K, V]) Range(from, to K, flag RangeFlag, f func(key K, value V) bool) {
+	for x := list.firstInRange(from, to, flag, nil); x != nil; x = x.level[0].forward {
+		if !list.beforeTo(x.key, to, flag) || !f(x.key, x.value) {
+			return
+		}
+	}
+}
+
+// RevRange 按key从大到小轮询[from, to]范围内的元素，边界是否包含由flag决定
+// Param：f 接受轮询的函数，返回true继续轮询，返回false终止轮询
+func (list *SkipListOf[K, V]) RevRange(from, to K, flag RangeFlag, f func(key K, value V) bool) {
+	for x := list.lastInRange(from, to, flag); x != nil; x = x.backward {
+		if !list.afterFrom(x.key, from, flag) || !f(x.key, x.value) {
+			return
+		}
+	}
+}
+
+// DeleteRange 删除[from, to]范围内的元素，边界是否包含由flag决定
+// Return：删除的元素个数
+func (list *SkipListOf[K, V]) DeleteRange(from, to K, flag RangeFlag) int {
+	update := make([]*NodeOf[K, V], list.maxLv)
+	x := list.firstInRange(from, to, flag, update)
+	removed := 0
+	for x != nil && list.beforeTo(x.key, to, flag) {
+		next := x.level[0].forward
+		list.deleteNode(x, update)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// Count 获得[from, to]范围内的元素个数，边界是否包含由flag决定，O(log n)
+func (list *SkipListOf[K, V]) Count(from, to K, flag RangeFlag) int {
+	n := list.countBefore(to, flag&RangeExcludeTo == 0) - list.countBefore(from, flag&RangeExcludeFrom != 0)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// 获得key小于searchKey的元素个数，inclusive为true时包含等于searchKey的元素
+func (list *SkipListOf[K, V]) countBefore(searchKey K, inclusive bool) int {
+	x := list.header
+	n := 0
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil {
+			c := list.keyCmp(x.level[i].forward.key, searchKey)
+			if c > 0 || (c == 0 && !inclusive) {
+				break
+			}
+			n += x.level[i].span
+			x = x.level[i].forward
+		}
+	}
+	return n
+}
+
+// Rank 获得key的排名（从0开始，按key从小到大），O(log n)
+// Return：key不存在返回-1
+func (list *SkipListOf[K, V]) Rank(searchKey K) int {
+	x := list.header
+	rank := 0
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && list.keyCmp(x.level[i].forward.key, searchKey) <= 0 {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != list.header && list.keyCmp(x.key, searchKey) == 0 {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// ByRank 获得排名为rank（从0开始，按key从小到大）的元素的迭代器，O(log n)
+// Return：rank越界返回nil
+func (list *SkipListOf[K, V]) ByRank(rank int) *IteratorOf[K, V] {
+	if rank < 0 || rank >= list.len {
+		return nil
+	}
+	x := list.header
+	traversed := 0
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank+1 {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank+1 {
+			return &IteratorOf[K, V]{n: x}
+		}
+	}
+	return nil
+}
+
+// LastNear 获得最后一个key小于等于searchKey的元素的迭代器
+func (list *SkipListOf[K, V]) LastNear(searchKey K) *IteratorOf[K, V] {
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && list.keyCmp(x.level[i].forward.key, searchKey) <= 0 {
+			x = x.level[i].forward
+		}
+	}
+	if x == list.header {
+		return nil
+	}
+	return &IteratorOf[K, V]{n: x}
+}
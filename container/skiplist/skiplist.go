@@ -16,19 +16,33 @@ const (
 )
 
 type Compare func(a, b interface{}) int
-type Opt func(*SkipList)
+type CompareOf[K any] func(a, b K) int
 
-type Node struct {
-	key     interface{}
-	value   interface{}
-	forward []*Node
+type Opt = OptOf[interface{}, interface{}]
+type OptOf[K, V any] func(*SkipListOf[K, V])
+
+type Node = NodeOf[interface{}, interface{}]
+type Iterator = IteratorOf[interface{}, interface{}]
+type SkipList = SkipListOf[interface{}, interface{}]
+
+type NodeOf[K, V any] struct {
+	key      K
+	value    V
+	backward *NodeOf[K, V]
+	level    []levelOf[K, V]
 }
 
-type Iterator struct {
-	n *Node
+type levelOf[K, V any] struct {
+	forward *NodeOf[K, V]
+	// 当前节点到forward节点之间跨越的节点数，用于计算排名
+	span int
 }
 
-type SkipList struct {
+type IteratorOf[K, V any] struct {
+	n *NodeOf[K, V]
+}
+
+type SkipListOf[K, V any] struct {
 	maxLv int
 	p     float32
 
@@ -36,15 +50,32 @@ type SkipList struct {
 	len   int
 
 	rand   *rand.Rand
-	header *Node
-	keyCmp Compare
+	header *NodeOf[K, V]
+	tail   *NodeOf[K, V]
+	keyCmp CompareOf[K]
 }
 
+// New 创建键值为interface{}的跳表，必须通过Opt配置键比较函数
 func New(opts ...Opt) *SkipList {
-	ret := &SkipList{
-		maxLv: SKIPLIST_MAX_LEVEL,
-		p:     SKIPLIST_P,
-		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	return newSkipList[interface{}, interface{}](nil, opts...)
+}
+
+// NewOf 创建键为有序类型的跳表，默认使用CompareOrdered比较键
+func NewOf[K Ordered, V any](opts ...OptOf[K, V]) *SkipListOf[K, V] {
+	return newSkipList[K, V](CompareOrdered[K], opts...)
+}
+
+// NewOfFunc 使用自定义比较函数创建跳表，适用于结构体等非有序类型的键
+func NewOfFunc[K, V any](cmp CompareOf[K], opts ...OptOf[K, V]) *SkipListOf[K, V] {
+	return newSkipList[K, V](cmp, opts...)
+}
+
+func newSkipList[K, V any](cmp CompareOf[K], opts ...OptOf[K, V]) *SkipListOf[K, V] {
+	ret := &SkipListOf[K, V]{
+		maxLv:  SKIPLIST_MAX_LEVEL,
+		p:      SKIPLIST_P,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		keyCmp: cmp,
 	}
 	for _, v := range opts {
 		v(ret)
@@ -53,127 +84,179 @@ func New(opts ...Opt) *SkipList {
 	if ret.keyCmp == nil {
 		panic("keyCmp or valueCmp is nil!")
 	}
-	ret.header = makeNode(ret.maxLv, nil, nil)
-	//fillForward(ret.header.forward)
+	if ret.maxLv < 1 {
+		ret.maxLv = 1
+	}
+	var (
+		k K
+		v V
+	)
+	ret.header = makeNode(ret.maxLv, k, v)
 	return ret
 }
 
 func SetKeyCompareFunc(cmp Compare) Opt {
 	return func(list *SkipList) {
-		list.keyCmp = cmp
+		list.keyCmp = CompareOf[interface{}](cmp)
 	}
 }
 
 func SetMaxLevel(maxLv int) Opt {
-	return func(list *SkipList) {
+	return SetMaxLevelOf[interface{}, interface{}](maxLv)
+}
+
+func SetP(p float32) Opt {
+	return SetPOf[interface{}, interface{}](p)
+}
+
+// SetKeyCompareFuncOf 配置泛型跳表的键比较函数
+func SetKeyCompareFuncOf[K, V any](cmp CompareOf[K]) OptOf[K, V] {
+	return func(list *SkipListOf[K, V]) {
+		list.keyCmp = cmp
+	}
+}
+
+// SetMaxLevelOf 配置泛型跳表的最大层数
+func SetMaxLevelOf[K, V any](maxLv int) OptOf[K, V] {
+	return func(list *SkipListOf[K, V]) {
 		list.maxLv = maxLv
 	}
 }
 
-func SetP(p float32) Opt {
-	return func(list *SkipList) {
+// SetPOf 配置泛型跳表节点晋升上一层的概率
+func SetPOf[K, V any](p float32) OptOf[K, V] {
+	return func(list *SkipListOf[K, V]) {
 		list.p = p
 	}
 }
 
-func randomLevel(rand *rand.Rand) int {
+// 返回新节点的层数，取值范围[1, maxLv]
+func (list *SkipListOf[K, V]) randomLevel() int {
 	level := 1
-	f := 0xFFFF * float32(SKIPLIST_P)
-	for ((rand.Int() & 0xFFFF) < int(f)) && (SKIPLIST_MAX_LEVEL > level) {
+	f := int(0xFFFF * list.p)
+	for ((list.rand.Int() & 0xFFFF) < f) && (list.maxLv > level) {
 		level += 1
 	}
 
 	return level
 }
 
-func (list *SkipList) Get(searchKey interface{}) interface{} {
+// 查找第一个键大于等于searchKey的节点，update不为nil时记录每一层的前驱节点
+func (list *SkipListOf[K, V]) findGreaterOrEqual(searchKey K, update []*NodeOf[K, V]) *NodeOf[K, V] {
 	x := list.header
-	i := list.level
-	for i >= 0 {
-		for x.forward[i] != nil && list.keyCmp(x.forward[i].key, searchKey) < 0 {
-			x = x.forward[i]
+	for i := list.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && list.keyCmp(x.level[i].forward.key, searchKey) < 0 {
+			x = x.level[i].forward
+		}
+		if update != nil {
+			update[i] = x
 		}
-		i--
 	}
-	x = x.forward[0]
+	return x.level[0].forward
+}
+
+func (list *SkipListOf[K, V]) Get(searchKey K) V {
+	v, _ := list.Lookup(searchKey)
+	return v
+}
+
+// Lookup 根据key获取value，key不存在时ok返回false
+func (list *SkipListOf[K, V]) Lookup(searchKey K) (value V, ok bool) {
+	x := list.findGreaterOrEqual(searchKey, nil)
 	if x != nil && list.keyCmp(x.key, searchKey) == 0 {
-		return x.value
-	} else {
-		return nil
+		return x.value, true
 	}
+	return value, false
 }
 
-func (list *SkipList) Set(searchKey, newValue interface{}) {
-	update := make([]*Node, list.maxLv)
+func (list *SkipListOf[K, V]) Set(searchKey K, newValue V) {
+	update := make([]*NodeOf[K, V], list.maxLv)
+	rank := make([]int, list.maxLv)
 	x := list.header
-	i := list.level
-	for i >= 0 {
-		for x.forward[i] != nil && list.keyCmp(x.forward[i].key, searchKey) < 0 {
-			x = x.forward[i]
+	for i := list.level - 1; i >= 0; i-- {
+		if i < list.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && list.keyCmp(x.level[i].forward.key, searchKey) < 0 {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
 		}
 		update[i] = x
-		i--
 	}
-	x = x.forward[0]
+	x = x.level[0].forward
 	if x != nil && list.keyCmp(x.key, searchKey) == 0 {
 		x.value = newValue
-	} else {
-		lvl := randomLevel(list.rand)
-		if lvl > list.level {
-			i := list.level + 1
-			for i <= lvl {
-				update[i] = list.header
-				i++
-			}
-			list.level = lvl
-		}
-		x = makeNode(lvl, searchKey, newValue)
-		i := 0
-		for i <= lvl {
-			x.forward[i] = update[i].forward[i]
-			update[i].forward[i] = x
-			i++
-		}
-		list.len++
+		return
 	}
-}
 
-func (list *SkipList) Delete(searchKey interface{}) bool {
-	update := make([]*Node, list.maxLv)
-	x := list.header
-	i := list.level
-	for i >= 0 {
-		for x.forward[i] != nil && list.keyCmp(x.forward[i].key, searchKey) < 0 {
-			x = x.forward[i]
+	lvl := list.randomLevel()
+	if lvl > list.level {
+		for i := list.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = list.header
+			update[i].level[i].span = list.len
 		}
-		update[i] = x
-		i--
+		list.level = lvl
+	}
+	x = makeNode(lvl, searchKey, newValue)
+	for i := 0; i < lvl; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
 	}
-	x = x.forward[0]
+	for i := lvl; i < list.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != list.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		list.tail = x
+	}
+	list.len++
+}
+
+func (list *SkipListOf[K, V]) Delete(searchKey K) bool {
+	update := make([]*NodeOf[K, V], list.maxLv)
+	x := list.findGreaterOrEqual(searchKey, update)
 	if x != nil && list.keyCmp(x.key, searchKey) == 0 {
-		i := 0
-		for i <= list.level {
-			if update[i].forward[i] != x {
-				break
-			}
-			update[i].forward[i] = x.forward[i]
-			i++
-		}
-		freeNode(x)
-		for list.level > 0 && list.header.forward[list.level] == nil {
-			list.level--
-		}
-		list.len--
+		list.deleteNode(x, update)
 		return true
 	}
 	return false
 }
 
-func (list *SkipList) Len() int {
+// 删除节点x，update为x每一层的前驱节点
+func (list *SkipListOf[K, V]) deleteNode(x *NodeOf[K, V], update []*NodeOf[K, V]) {
+	for i := 0; i < list.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		list.tail = x.backward
+	}
+	for list.level > 1 && list.header.level[list.level-1].forward == nil {
+		list.level--
+	}
+	list.len--
+	freeNode(x)
+}
+
+func (list *SkipListOf[K, V]) Len() int {
 	return list.len
 }
 
-func (list *SkipList) Values(size int) []interface{} {
+func (list *SkipListOf[K, V]) Values(size int) []V {
 	if size < 0 {
 		size = list.len
 	}
@@ -182,100 +265,98 @@ func (list *SkipList) Values(size int) []interface{} {
 		return nil
 	}
 
-	ret := make([]interface{}, size)
+	ret := make([]V, size)
 	i := 0
-	x := list.header.forward[0]
+	x := list.header.level[0].forward
 	for x != nil {
 		if i >= size {
 			break
 		}
 		ret[i] = x.value
-		x = x.forward[0]
+		x = x.level[0].forward
 		i++
 	}
 	return ret
 }
 
-func (list *SkipList) First() *Iterator {
-	if list.header.forward[0] == nil {
+func (list *SkipListOf[K, V]) First() *IteratorOf[K, V] {
+	if list.header.level[0].forward == nil {
 		return nil
 	}
-	return &Iterator{n: list.header.forward[0]}
+	return &IteratorOf[K, V]{n: list.header.level[0].forward}
 }
 
-func (list *SkipList) Last() *Iterator {
-	x := list.header.forward[0]
-	if x == nil {
+// Last 获得最后一个元素的迭代器，O(1)
+func (list *SkipListOf[K, V]) Last() *IteratorOf[K, V] {
+	if list.tail == nil {
 		return nil
 	}
-	for x != nil {
-		if x.forward[0] == nil {
-			return &Iterator{n: x}
-		}
-		x = x.forward[0]
-	}
-	return nil
+	return &IteratorOf[K, V]{n: list.tail}
 }
 
-func (list *SkipList) FirstNear(searchKey interface{}) *Iterator {
-	x := list.header
-	i := list.level
-	for i >= 0 {
-		for x.forward[i] != nil && list.keyCmp(x.forward[i].key, searchKey) < 0 {
-			x = x.forward[i]
-		}
-		i--
-	}
-	x = x.forward[0]
+func (list *SkipListOf[K, V]) FirstNear(searchKey K) *IteratorOf[K, V] {
+	x := list.findGreaterOrEqual(searchKey, nil)
 	if x == nil {
 		return nil
 	}
-	return &Iterator{n: x}
+	return &IteratorOf[K, V]{n: x}
 }
 
-func (it *Iterator) Next() *Iterator {
+func (it *IteratorOf[K, V]) Next() *IteratorOf[K, V] {
 	if it.n == nil {
 		return nil
 	}
-	it.n = it.n.forward[0]
+	it.n = it.n.level[0].forward
 	if it.n == nil {
 		return nil
 	}
 	return it
 }
 
-func (it *Iterator) Key() interface{} {
+// Prev 移动到前一个元素，已经是第一个元素时返回nil
+func (it *IteratorOf[K, V]) Prev() *IteratorOf[K, V] {
 	if it.n == nil {
 		return nil
 	}
+	it.n = it.n.backward
+	if it.n == nil {
+		return nil
+	}
+	return it
+}
+
+func (it *IteratorOf[K, V]) Key() (key K) {
+	if it.n == nil {
+		return key
+	}
 
 	return it.n.key
 }
 
-func (it *Iterator) Value() interface{} {
+func (it *IteratorOf[K, V]) Value() (value V) {
 	if it.n == nil {
-		return nil
+		return value
 	}
 
 	return it.n.value
 }
 
-func (it *Iterator) KeyValue() (interface{}, interface{}) {
+func (it *IteratorOf[K, V]) KeyValue() (key K, value V) {
 	if it.n == nil {
-		return nil, nil
+		return key, value
 	}
 
-	return it.n.value, it.n.value
+	return it.n.key, it.n.value
 }
 
-func makeNode(lvl int, searchKey, newValue interface{}) *Node {
-	return &Node{
-		key:     searchKey,
-		value:   newValue,
-		forward: make([]*Node, lvl+1),
+func makeNode[K, V any](lvl int, searchKey K, newValue V) *NodeOf[K, V] {
+	return &NodeOf[K, V]{
+		key:   searchKey,
+		value: newValue,
+		level: make([]levelOf[K, V], lvl),
 	}
 }
 
-func freeNode(n *Node) {
+func freeNode[K, V any](n *NodeOf[K, V]) {
 
 }
@@ -0,0 +1,212 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package xmap
+
+import "container/list"
+
+type nodeOf[K comparable, V any] struct {
+	prev  *nodeOf[K, V]
+	next  *nodeOf[K, V]
+	key   K
+	value V
+}
+
+func (n *nodeOf[K, V]) init() {
+	var (
+		k K
+		v V
+	)
+	n.prev = nil
+	n.next = nil
+	n.key = k
+	n.value = v
+}
+
+// LinkedMapOf 按插入顺序轮询的泛型Map
+type LinkedMapOf[K comparable, V any] struct {
+	head nodeOf[K, V]
+	m    map[K]*nodeOf[K, V]
+}
+
+func NewLinkedMapOf[K comparable, V any]() *LinkedMapOf[K, V] {
+	ret := &LinkedMapOf[K, V]{
+		m: make(map[K]*nodeOf[K, V]),
+	}
+	ret.head.init()
+	return ret
+}
+
+func (m *LinkedMapOf[K, V]) init() {
+	if m.head.next == nil {
+		m.head.next = &m.head
+		m.head.prev = &m.head
+	}
+}
+
+func (m *LinkedMapOf[K, V]) insert(key K, value V, at *nodeOf[K, V]) *nodeOf[K, V] {
+	e := &nodeOf[K, V]{key: key, value: value}
+	n := at.next
+	at.next = e
+	e.prev = at
+	e.next = n
+	n.prev = e
+
+	return e
+}
+
+// 向Map中添加一个元素，已存在的key保持原有顺序
+// Param：key 添加的对象key，value 添加的对象
+func (m *LinkedMapOf[K, V]) Put(key K, value V) {
+	if e, ok := m.m[key]; ok {
+		e.value = value
+		return
+	}
+
+	m.init()
+	m.m[key] = m.insert(key, value, m.head.prev)
+}
+
+// 尝试向Map中添加一个元素，如果已存在该元素则直接返回已存在元素不进行添加
+// Param：key 添加的对象key，value 添加的对象
+// Return： actual 如果key已存在对应元素，则返回该元素，否则返回新添加的元素。 loaded：已存在返回true，否则返回false
+func (m *LinkedMapOf[K, V]) GetOrPut(key K, value V) (actual V, loaded bool) {
+	o, ok := m.m[key]
+	if ok {
+		return o.value, true
+	}
+
+	m.init()
+	m.m[key] = m.insert(key, value, m.head.prev)
+	return value, false
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *LinkedMapOf[K, V]) Get(key K) (value V, loaded bool) {
+	o, ok := m.m[key]
+	if ok {
+		return o.value, true
+	}
+	return value, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *LinkedMapOf[K, V]) Delete(key K) {
+	if n, ok := m.m[key]; ok {
+		n.prev.next = n.next
+		n.next.prev = n.prev
+		n.init()
+		delete(m.m, key)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *LinkedMapOf[K, V]) Size() int {
+	return len(m.m)
+}
+
+// 按插入顺序轮询Map O(N)
+// Param：接受轮询的函数，返回true继续轮询，返回false终止轮询
+func (m *LinkedMapOf[K, V]) Foreach(f func(key K, value V) bool) {
+	for e := m.head.next; e != nil && e != &m.head; e = e.next {
+		if !f(e.key, e.value) {
+			break
+		}
+	}
+}
+
+// 查询Map中是否存在参数对象
+// Param：查询的对象
+// Return：存在返回true，不存在返回false
+func (m *LinkedMapOf[K, V]) Find(key K) bool {
+	_, ok := m.m[key]
+	return ok
+}
+
+type entryOf[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// SimpleLinkedMapOf 基于container/list实现的按插入顺序轮询的泛型Map
+type SimpleLinkedMapOf[K comparable, V any] struct {
+	l *list.List
+	m map[K]*list.Element
+}
+
+func NewSimpleLinkedMapOf[K comparable, V any]() *SimpleLinkedMapOf[K, V] {
+	return &SimpleLinkedMapOf[K, V]{list.New(), make(map[K]*list.Element)}
+}
+
+// 向Map中添加一个元素，已存在的key保持原有顺序
+// Param：key 添加的对象key，value 添加的对象
+func (m *SimpleLinkedMapOf[K, V]) Put(key K, value V) {
+	if e, ok := m.m[key]; ok {
+		e.Value.(*entryOf[K, V]).value = value
+		return
+	}
+	m.m[key] = m.l.PushBack(&entryOf[K, V]{key: key, value: value})
+}
+
+// 尝试向Map中添加一个元素，如果已存在该元素则直接返回已存在元素不进行添加
+// Param：key 添加的对象key，value 添加的对象
+// Return： actual 如果key已存在对应元素，则返回该元素，否则返回新添加的元素。 loaded：已存在返回true，否则返回false
+func (m *SimpleLinkedMapOf[K, V]) GetOrPut(key K, value V) (actual V, loaded bool) {
+	o, ok := m.m[key]
+	if ok {
+		return o.Value.(*entryOf[K, V]).value, true
+	}
+	m.m[key] = m.l.PushBack(&entryOf[K, V]{key: key, value: value})
+	return value, false
+}
+
+// 获取key对应的元素
+// Param：key 对象key
+// Return： value：key对应的对象，loaded：成功获取返回true，不存在返回false
+func (m *SimpleLinkedMapOf[K, V]) Get(key K) (value V, loaded bool) {
+	o, ok := m.m[key]
+	if ok {
+		return o.Value.(*entryOf[K, V]).value, true
+	}
+	return value, false
+}
+
+// 删除key对应的元素
+// Param：key
+func (m *SimpleLinkedMapOf[K, V]) Delete(key K) {
+	if e, ok := m.m[key]; ok {
+		m.l.Remove(e)
+		delete(m.m, key)
+	}
+}
+
+// 获得Map长度
+// Return： 链表长度
+func (m *SimpleLinkedMapOf[K, V]) Size() int {
+	return len(m.m)
+}
+
+// 按插入顺序轮询Map O(N)
+// Param：接受轮询的函数，返回true继续轮询，返回false终止轮询
+func (m *SimpleLinkedMapOf[K, V]) Foreach(f func(key K, value V) bool) {
+	for e := m.l.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entryOf[K, V])
+		if !f(kv.key, kv.value) {
+			break
+		}
+	}
+}
+
+// 查询Map中是否存在参数对象
+// Param：查询的对象
+// Return：存在返回true，不存在返回false
+func (m *SimpleLinkedMapOf[K, V]) Find(key K) bool {
+	_, ok := m.m[key]
+	return ok
+}
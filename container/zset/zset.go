@@ -0,0 +1,249 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package zset
+
+import (
+	"errors"
+	"github.com/xfali/goutils/v2/container/skiplist"
+	"math"
+)
+
+// ErrNaN 分数或者IncrBy计算后的分数为NaN
+var ErrNaN = errors.New("zset: score is not a number ")
+
+// Z 有序集合中的元素
+type Z[M skiplist.Ordered] struct {
+	Member M
+	Score  float64
+}
+
+type zkey[M skiplist.Ordered] struct {
+	score  float64
+	member M
+	// 范围查询的边界标志：-1小于同分数的所有成员，1大于同分数的所有成员，0为普通成员
+	bound int8
+}
+
+func compareKey[M skiplist.Ordered](a, b zkey[M]) int {
+	if c := skiplist.CompareOrdered(a.score, b.score); c != 0 {
+		return c
+	}
+	if a.bound != b.bound {
+		return skiplist.CompareOrdered(a.bound, b.bound)
+	}
+	return skiplist.CompareOrdered(a.member, b.member)
+}
+
+// ZSet 按分数排序的成员集合，语义与Redis ZSET一致：分数相同时按成员字典序排序
+// 非线程安全
+type ZSet[M skiplist.Ordered] struct {
+	list *skiplist.SkipListOf[zkey[M], struct{}]
+	dict map[M]float64
+}
+
+func New[M skiplist.Ordered]() *ZSet[M] {
+	return &ZSet[M]{
+		list: skiplist.NewOfFunc[zkey[M], struct{}](compareKey[M]),
+		dict: map[M]float64{},
+	}
+}
+
+// Add 添加成员，成员已存在时更新分数，score为NaN时返回ErrNaN
+// Return：新添加返回true，更新返回false
+func (z *ZSet[M]) Add(member M, score float64) (bool, error) {
+	if math.IsNaN(score) {
+		return false, ErrNaN
+	}
+	if old, ok := z.dict[member]; ok {
+		if old != score {
+			z.list.Delete(zkey[M]{score: old, member: member})
+			z.list.Set(zkey[M]{score: score, member: member}, struct{}{})
+			z.dict[member] = score
+		}
+		return false, nil
+	}
+	z.list.Set(zkey[M]{score: score, member: member}, struct{}{})
+	z.dict[member] = score
+	return true, nil
+}
+
+// IncrBy 为成员的分数增加delta，成员不存在时以delta为分数添加
+// delta或者增加后的分数为NaN（如-Inf增加+Inf）时返回ErrNaN，分数不变
+// Return：增加后的分数
+func (z *ZSet[M]) IncrBy(member M, delta float64) (float64, error) {
+	score := z.dict[member] + delta
+	if _, err := z.Add(member, score); err != nil {
+		return 0, err
+	}
+	return score, nil
+}
+
+// Score 获得成员的分数
+func (z *ZSet[M]) Score(member M) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Rem 删除成员
+// Return：删除的成员个数
+func (z *ZSet[M]) Rem(members ...M) int {
+	removed := 0
+	for _, member := range members {
+		if score, ok := z.dict[member]; ok {
+			z.list.Delete(zkey[M]{score: score, member: member})
+			delete(z.dict, member)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Card 获得成员个数
+func (z *ZSet[M]) Card() int {
+	return len(z.dict)
+}
+
+// Rank 获得成员按分数从小到大的排名（从0开始），成员不存在返回-1
+func (z *ZSet[M]) Rank(member M) int {
+	score, ok := z.dict[member]
+	if !ok {
+		return -1
+	}
+	return z.list.Rank(zkey[M]{score: score, member: member})
+}
+
+// RevRank 获得成员按分数从大到小的排名（从0开始），成员不存在返回-1
+func (z *ZSet[M]) RevRank(member M) int {
+	rank := z.Rank(member)
+	if rank < 0 {
+		return -1
+	}
+	return z.list.Len() - 1 - rank
+}
+
+// 将Redis风格的[start, stop]排名（支持负数表示倒数）转换为有效区间
+func (z *ZSet[M]) rankRange(start, stop int) (int, int, bool) {
+	size := z.list.Len()
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop || start >= size {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// RangeByRank 按分数从小到大获得排名在[start, stop]之间的成员，负数表示倒数第几个，如-1为最后一个
+func (z *ZSet[M]) RangeByRank(start, stop int) []Z[M] {
+	start, stop, ok := z.rankRange(start, stop)
+	if !ok {
+		return nil
+	}
+	ret := make([]Z[M], 0, stop-start+1)
+	for it := z.list.ByRank(start); it != nil && len(ret) < cap(ret); it = it.Next() {
+		k := it.Key()
+		ret = append(ret, Z[M]{Member: k.member, Score: k.score})
+	}
+	return ret
+}
+
+// RevRange 按分数从大到小获得排名在[start, stop]之间的成员，负数表示倒数第几个，如-1为最后一个
+func (z *ZSet[M]) RevRange(start, stop int) []Z[M] {
+	start, stop, ok := z.rankRange(start, stop)
+	if !ok {
+		return nil
+	}
+	ret := make([]Z[M], 0, stop-start+1)
+	for it := z.list.ByRank(z.list.Len() - 1 - start); it != nil && len(ret) < cap(ret); it = it.Prev() {
+		k := it.Key()
+		ret = append(ret, Z[M]{Member: k.member, Score: k.score})
+	}
+	return ret
+}
+
+// 将分数区间转换为跳表查询的边界key，flag决定是否包含min与max
+func scoreRange[M skiplist.Ordered](min, max float64, flag skiplist.RangeFlag) (from, to zkey[M]) {
+	from = zkey[M]{score: min, bound: -1}
+	if flag&skiplist.RangeExcludeFrom != 0 {
+		from.bound = 1
+	}
+	to = zkey[M]{score: max, bound: 1}
+	if flag&skiplist.RangeExcludeTo != 0 {
+		to.bound = -1
+	}
+	return from, to
+}
+
+// RangeByScore 按分数从小到大获得分数在[min, max]之间的成员，边界是否包含由flag决定
+func (z *ZSet[M]) RangeByScore(min, max float64, flag skiplist.RangeFlag) []Z[M] {
+	from, to := scoreRange[M](min, max, flag)
+	var ret []Z[M]
+	z.list.Range(from, to, skiplist.RangeInclude, func(k zkey[M], _ struct{}) bool {
+		ret = append(ret, Z[M]{Member: k.member, Score: k.score})
+		return true
+	})
+	return ret
+}
+
+// RevRangeByScore 按分数从大到小获得分数在[min, max]之间的成员，边界是否包含由flag决定
+func (z *ZSet[M]) RevRangeByScore(min, max float64, flag skiplist.RangeFlag) []Z[M] {
+	from, to := scoreRange[M](min, max, flag)
+	var ret []Z[M]
+	z.list.RevRange(from, to, skiplist.RangeInclude, func(k zkey[M], _ struct{}) bool {
+		ret = append(ret, Z[M]{Member: k.member, Score: k.score})
+		return true
+	})
+	return ret
+}
+
+// Count 获得分数在[min, max]之间的成员个数，边界是否包含由flag决定，O(log n)
+func (z *ZSet[M]) Count(min, max float64, flag skiplist.RangeFlag) int {
+	from, to := scoreRange[M](min, max, flag)
+	return z.list.Count(from, to, skiplist.RangeInclude)
+}
+
+// RemRangeByScore 删除分数在[min, max]之间的成员，边界是否包含由flag决定
+// Return：删除的成员个数
+func (z *ZSet[M]) RemRangeByScore(min, max float64, flag skiplist.RangeFlag) int {
+	for _, e := range z.RangeByScore(min, max, flag) {
+		delete(z.dict, e.Member)
+	}
+	from, to := scoreRange[M](min, max, flag)
+	return z.list.DeleteRange(from, to, skiplist.RangeInclude)
+}
+
+// PopMin 删除并返回分数最小的count个成员
+func (z *ZSet[M]) PopMin(count int) []Z[M] {
+	if count <= 0 {
+		return nil
+	}
+	ret := z.RangeByRank(0, count-1)
+	for _, e := range ret {
+		z.Rem(e.Member)
+	}
+	return ret
+}
+
+// PopMax 删除并返回分数最大的count个成员，按分数从大到小排列
+func (z *ZSet[M]) PopMax(count int) []Z[M] {
+	if count <= 0 {
+		return nil
+	}
+	ret := z.RevRange(0, count-1)
+	for _, e := range ret {
+		z.Rem(e.Member)
+	}
+	return ret
+}
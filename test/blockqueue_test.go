@@ -11,8 +11,10 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"github.com/xfali/goutils/v2/container"
+	"sync"
 	"testing"
 	"time"
 )
@@ -93,3 +95,457 @@ func TestBlockQueue3(t *testing.T) {
 	}
 	stop = true
 }
+
+func TestBlockQueueContext(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		bq := container.NewBlockQueue(1)
+		if _, err := bq.DequeueTimeout(10 * time.Millisecond); err != context.DeadlineExceeded {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+		if err := bq.EnqueueTimeout(1, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if err := bq.EnqueueTimeout(2, 10*time.Millisecond); err != context.DeadlineExceeded {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+		if v, err := bq.DequeueTimeout(10 * time.Millisecond); err != nil || v != 1 {
+			t.Fatal("expect 1 but get ", v, err)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		bq := container.NewBlockQueue(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error)
+		go func() {
+			_, err := bq.DequeueContext(ctx)
+			errCh <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-errCh:
+			if err != context.Canceled {
+				t.Fatal("expect Canceled but get ", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expect dequeue canceled")
+		}
+		if _, err := bq.DequeueContext(ctx); err != context.Canceled {
+			t.Fatal("expect Canceled but get ", err)
+		}
+	})
+
+	t.Run("wake by data", func(t *testing.T) {
+		bq := container.NewBlockQueue(1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		ch := make(chan interface{})
+		go func() {
+			v, _ := bq.DequeueContext(ctx)
+			ch <- v
+		}()
+		time.Sleep(10 * time.Millisecond)
+		bq.Enqueue("a")
+		if v := <-ch; v != "a" {
+			t.Fatal("expect a but get ", v)
+		}
+	})
+
+	t.Run("peek", func(t *testing.T) {
+		bq := container.NewBlockQueue(2)
+		if _, ok := bq.Peek(); ok {
+			t.Fatal("expect empty")
+		}
+		if bq.First() != nil {
+			t.Fatal("expect nil")
+		}
+		bq.Enqueue(nil)
+		if v, ok := bq.Peek(); !ok || v != nil {
+			t.Fatal("expect nil element")
+		}
+		if bq.Len() != 1 {
+			t.Fatal("expect 1")
+		}
+	})
+}
+
+func TestBlockQueueClose(t *testing.T) {
+	bq := container.NewBlockQueue(1)
+	bq.Enqueue(1)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- bq.EnqueueContext(context.Background(), 2)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	bq.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+	}
+
+	// 关闭后可以取出剩余数据
+	if v, err := bq.DequeueContext(context.Background()); err != nil || v != 1 {
+		t.Fatal("expect 1 but get ", v, err)
+	}
+	if _, err := bq.DequeueContext(context.Background()); err != container.ErrClosed {
+		t.Fatal("expect ErrClosed but get ", err)
+	}
+	if bq.Dequeue() != nil {
+		t.Fatal("expect nil after close")
+	}
+	if bq.TryEnqueue(1) {
+		t.Fatal("expect TryEnqueue fail after close")
+	}
+	if err := bq.EnqueueTimeout(1, time.Millisecond); err != container.ErrClosed {
+		t.Fatal("expect ErrClosed but get ", err)
+	}
+
+	// 唤醒阻塞的消费者
+	bq2 := container.NewBlockQueue(1)
+	done := make(chan struct{})
+	go func() {
+		bq2.Dequeue()
+		bq2.WaitOne(func(data interface{}) bool {
+			return true
+		})
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	bq2.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expect consumer woken by Close")
+	}
+}
+
+func TestBlockQueueOf(t *testing.T) {
+	t.Run("batch", func(t *testing.T) {
+		bq := container.NewBlockQueueOf[int](10)
+		if err := bq.EnqueueAll(1, 2, 3); err != nil {
+			t.Fatal(err)
+		}
+		if v := bq.Dequeue(); v != 1 {
+			t.Fatal("expect 1 but get ", v)
+		}
+		buf := bq.DrainTo(nil, 1)
+		if len(buf) != 1 || buf[0] != 2 {
+			t.Fatal("expect [2] but get ", buf)
+		}
+		buf = bq.DrainTo(buf, 0)
+		if len(buf) != 2 || buf[1] != 3 {
+			t.Fatal("expect [2 3] but get ", buf)
+		}
+		if bq.Len() != 0 {
+			t.Fatal("expect empty")
+		}
+	})
+
+	t.Run("enqueue all blocking", func(t *testing.T) {
+		bq := container.NewBlockQueueOf[int](3)
+		items := make([]int, 100)
+		for i := range items {
+			items[i] = i
+		}
+		go bq.EnqueueAll(items...)
+		var got []int
+		for len(got) < 100 {
+			batch, err := bq.DequeueBatch(1, 2, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(batch) > 2 {
+				t.Fatal("expect at most 2 but get ", len(batch))
+			}
+			got = append(got, batch...)
+		}
+		for i, v := range got {
+			if v != i {
+				t.Fatal("expect ordered but get ", got)
+			}
+		}
+	})
+
+	t.Run("dequeue batch min", func(t *testing.T) {
+		bq := container.NewBlockQueueOf[int](10)
+		go func() {
+			for i := 0; i < 5; i++ {
+				time.Sleep(5 * time.Millisecond)
+				bq.Enqueue(i)
+			}
+		}()
+		batch, err := bq.DequeueBatch(5, 10, time.Second)
+		if err != nil || len(batch) != 5 {
+			t.Fatal("expect 5 items but get ", batch, err)
+		}
+
+		bq.Enqueue(1)
+		start := time.Now()
+		batch, err = bq.DequeueBatch(3, 10, 20*time.Millisecond)
+		if err != nil || len(batch) != 1 || time.Since(start) < 20*time.Millisecond {
+			t.Fatal("expect 1 item after timeout but get ", batch, err)
+		}
+		if _, err = bq.DequeueBatch(3, 10, 10*time.Millisecond); err != context.DeadlineExceeded {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+		// min大于容量时按容量等待
+		small := container.NewBlockQueueOf[int](2)
+		small.EnqueueAll(1, 2)
+		if batch, err := small.DequeueBatch(5, 5, time.Second); err != nil || len(batch) != 2 {
+			t.Fatal("expect 2 items but get ", batch, err)
+		}
+
+		bq.Enqueue(1)
+		bq.Close()
+		if batch, err := bq.DequeueBatch(3, 10, -1); err != nil || len(batch) != 1 {
+			t.Fatal("expect remaining item but get ", batch, err)
+		}
+		if _, err := bq.DequeueBatch(3, 10, -1); err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+		if err := bq.EnqueueAll(1, 2); err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+	})
+
+	t.Run("unbounded", func(t *testing.T) {
+		bq := container.NewBlockQueueOf[string](-1)
+		for i := 0; i < 100; i++ {
+			if !bq.TryEnqueue("a") {
+				t.Fatal("expect unbounded queue")
+			}
+		}
+		bq.EnqueueAll("b", "c")
+		if bq.Len() != 102 {
+			t.Fatal("expect 102 but get ", bq.Len())
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		bq := container.NewBlockQueueOf[int](16)
+		var wg sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 250; i++ {
+					if i%2 == 0 {
+						bq.Enqueue(1)
+					} else {
+						bq.EnqueueAll(1, 1)
+					}
+				}
+			}()
+		}
+		total := make(chan int)
+		for c := 0; c < 3; c++ {
+			go func() {
+				n := 0
+				for {
+					batch, err := bq.DequeueBatch(4, 8, 50*time.Millisecond)
+					if err == container.ErrClosed {
+						total <- n
+						return
+					}
+					n += len(batch)
+				}
+			}()
+		}
+		wg.Wait()
+		bq.Close()
+		sum := <-total + <-total + <-total
+		if sum != 1500 {
+			t.Fatal("expect 1500 but get ", sum)
+		}
+	})
+}
+
+func BenchmarkBlockQueueOf(b *testing.B) {
+	b.Run("single", func(b *testing.B) {
+		bq := container.NewBlockQueueOf[int](1024)
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < b.N; i++ {
+				bq.Dequeue()
+			}
+			close(done)
+		}()
+		for i := 0; i < b.N; i++ {
+			bq.Enqueue(i)
+		}
+		<-done
+	})
+	b.Run("batch", func(b *testing.B) {
+		bq := container.NewBlockQueueOf[int](1024)
+		done := make(chan struct{})
+		go func() {
+			for n := 0; n < b.N; {
+				batch, _ := bq.DequeueBatch(1, 64, -1)
+				n += len(batch)
+			}
+			close(done)
+		}()
+		items := make([]int, 64)
+		for i := 0; i < b.N; i += len(items) {
+			if b.N-i < len(items) {
+				items = items[:b.N-i]
+			}
+			bq.EnqueueAll(items...)
+		}
+		<-done
+	})
+}
+
+func TestPriorityBlockQueue(t *testing.T) {
+	type job struct {
+		priority int
+		name     string
+	}
+	pq := container.NewPriorityBlockQueue[job](4, func(a, b job) bool {
+		return a.priority > b.priority
+	})
+	pq.EnqueueAll(job{1, "a"}, job{3, "b"}, job{2, "c"}, job{3, "d"})
+	if pq.TryEnqueue(job{5, "e"}) {
+		t.Fatal("expect queue full")
+	}
+	if v, ok := pq.Peek(); !ok || v.name != "b" {
+		t.Fatal("expect b but get ", v)
+	}
+	expect := []string{"b", "d", "c", "a"}
+	for _, name := range expect {
+		if v := pq.Dequeue(); v.name != name {
+			t.Fatal("expect ", name, " but get ", v.name)
+		}
+	}
+	if _, err := pq.DequeueTimeout(10 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatal("expect DeadlineExceeded but get ", err)
+	}
+
+	ints := container.NewPriorityBlockQueue[int](-1, func(a, b int) bool {
+		return a < b
+	})
+	for _, v := range []int{5, 3, 9, 1, 7, 2, 8} {
+		ints.Enqueue(v)
+	}
+	if got := ints.DrainTo(nil, 3); got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Fatal("expect [1 2 3] but get ", got)
+	}
+	if got, _ := ints.DequeueBatch(1, 10, 0); len(got) != 4 || got[0] != 5 || got[3] != 9 {
+		t.Fatal("expect [5 7 8 9] but get ", got)
+	}
+
+	// 阻塞与关闭
+	done := make(chan error)
+	go func() {
+		_, err := pq.DequeueContext(context.Background())
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pq.Close()
+	if err := <-done; err != container.ErrClosed {
+		t.Fatal("expect ErrClosed but get ", err)
+	}
+}
+
+func TestDelayQueue(t *testing.T) {
+	dq := container.NewDelayQueue[string](-1)
+	start := time.Now()
+	dq.Enqueue("c", 60*time.Millisecond)
+	dq.Enqueue("a", 20*time.Millisecond)
+	dq.Enqueue("b", 40*time.Millisecond)
+
+	if v, at, ok := dq.Peek(); !ok || v != "a" || at.Before(start) {
+		t.Fatal("expect a but get ", v)
+	}
+	if got := dq.DrainTo(nil, 0); len(got) != 0 {
+		t.Fatal("expect nothing ready but get ", got)
+	}
+	if _, err := dq.DequeueTimeout(5 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatal("expect DeadlineExceeded but get ", err)
+	}
+	for _, expect := range []string{"a", "b", "c"} {
+		v := dq.Dequeue()
+		if v != expect {
+			t.Fatal("expect ", expect, " but get ", v)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatal("expect wait until due but elapsed ", elapsed)
+	}
+
+	// 等待中入队更早到期的元素
+	dq.Enqueue("late", time.Hour)
+	result := make(chan string)
+	go func() {
+		result <- dq.Dequeue()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	dq.Enqueue("early", 10*time.Millisecond)
+	select {
+	case v := <-result:
+		if v != "early" {
+			t.Fatal("expect early but get ", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect early dequeued")
+	}
+
+	// 有界队列
+	bounded := container.NewDelayQueue[int](1)
+	if !bounded.TryEnqueue(1, 0) || bounded.TryEnqueue(2, 0) {
+		t.Fatal("expect capacity 1")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bounded.EnqueueContext(ctx, 2, 0); err != context.DeadlineExceeded {
+		t.Fatal("expect DeadlineExceeded but get ", err)
+	}
+}
+
+func TestDelayQueueClose(t *testing.T) {
+	dq := container.NewDelayQueue[int](1)
+	dq.Enqueue(1, 0)
+	errCh := make(chan error)
+	go func() {
+		errCh <- dq.EnqueueContext(context.Background(), 2, time.Hour)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	dq.Close()
+	if err := <-errCh; err != container.ErrClosed {
+		t.Fatal("expect ErrClosed but get ", err)
+	}
+	// 关闭后可以取出已到期的元素
+	if v, err := dq.DequeueContext(context.Background()); err != nil || v != 1 {
+		t.Fatal("expect 1 but get ", v, err)
+	}
+	if _, err := dq.DequeueContext(context.Background()); err != container.ErrClosed {
+		t.Fatal("expect ErrClosed but get ", err)
+	}
+
+	dq2 := container.NewDelayQueue[int](-1)
+	dq2.Enqueue(1, time.Hour)
+	go func() {
+		_, err := dq2.DequeueContext(context.Background())
+		errCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	dq2.Close()
+	select {
+	case err := <-errCh:
+		if err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect consumer woken by Close")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"github.com/xfali/goutils/v2/container/skiplist"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList(t *testing.T) {
+	slist := skiplist.NewConcurrent[int, string]()
+	if slist.First() != nil || slist.Last() != nil {
+		t.Fatal("empty list must have no iterator")
+	}
+	slist.Set(3, "s3")
+	slist.Set(1, "s1")
+	slist.Set(2, "s2")
+	slist.Set(2, "s2-2")
+	if slist.Len() != 3 {
+		t.Fatal("expect 3 but get ", slist.Len())
+	}
+	if v := slist.Get(2); v != "s2-2" {
+		t.Fatal("expect s2-2 but get ", v)
+	}
+	if !slist.Delete(2) || slist.Delete(2) {
+		t.Fatal("delete 2 failed")
+	}
+	if _, ok := slist.Lookup(2); ok {
+		t.Fatal("2 must be deleted")
+	}
+	if it := slist.FirstNear(2); it == nil || it.Key() != 3 {
+		t.Fatal("FirstNear 2 must be 3")
+	}
+	if it := slist.Last(); it == nil || it.Key() != 3 {
+		t.Fatal("last must be 3")
+	}
+	values := slist.Values(-1)
+	if len(values) != 2 || values[0] != "s1" || values[1] != "s3" {
+		t.Fatal("values not match: ", values)
+	}
+}
+
+func TestConcurrentSkipListRace(t *testing.T) {
+	slist := skiplist.NewConcurrent[int, int]()
+	const (
+		writers = 8
+		keys    = 2000
+	)
+
+	wait := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wait.Add(1)
+		go func(w int) {
+			defer wait.Done()
+			for i := w; i < keys; i += writers {
+				slist.Set(i, i)
+			}
+			for i := w; i < keys; i += writers {
+				if i%2 == 1 {
+					if !slist.Delete(i) {
+						t.Error("delete failed: ", i)
+					}
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for n := 0; n < 20; n++ {
+				last := -1
+				for it := slist.First(); it != nil; it = it.Next() {
+					k, v := it.KeyValue()
+					if k <= last {
+						t.Error("iterator out of order: ", last, k)
+						return
+					}
+					if k != v {
+						t.Error("key value not match: ", k, v)
+						return
+					}
+					last = k
+				}
+				slist.Get(n)
+				slist.Len()
+			}
+		}()
+	}
+	wait.Wait()
+
+	if slist.Len() != keys/2 {
+		t.Fatal("expect ", keys/2, " but get ", slist.Len())
+	}
+	i := 0
+	for it := slist.First(); it != nil; it = it.Next() {
+		if it.Key() != i {
+			t.Fatal("expect ", i, " but get ", it.Key())
+		}
+		i += 2
+	}
+}
+
+func TestConcurrentSkipListIteratorWhileDelete(t *testing.T) {
+	slist := skiplist.NewConcurrent[int, int]()
+	for i := 0; i < 1000; i++ {
+		slist.Set(i, i)
+	}
+
+	it := slist.First()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			slist.Delete(i)
+		}
+	}()
+	last := -1
+	for ; it != nil; it = it.Next() {
+		if it.Key() <= last {
+			t.Fatal("iterator out of order: ", last, it.Key())
+		}
+		last = it.Key()
+	}
+	<-done
+	if slist.Len() != 0 || slist.First() != nil {
+		t.Fatal("list must be empty")
+	}
+}
+
+func TestConcurrentSkipListSameKey(t *testing.T) {
+	slist := skiplist.NewConcurrent[int, int]()
+	wait := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wait.Add(1)
+		go func(w int) {
+			defer wait.Done()
+			for i := 0; i < 1000; i++ {
+				if i%2 == 0 {
+					slist.Set(i%10, w)
+				} else {
+					slist.Delete(i % 10)
+				}
+			}
+		}(w)
+	}
+	wait.Wait()
+	n := 0
+	for it := slist.First(); it != nil; it = it.Next() {
+		n++
+	}
+	if n != slist.Len() {
+		t.Fatal("expect ", n, " but get ", slist.Len())
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/xfali/goutils/v2/container/diskqueue"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func enqueueN(t *testing.T, q *diskqueue.Queue, from, to int) {
+	for i := from; i < to; i++ {
+		offset, err := q.Enqueue([]byte(fmt.Sprintf("msg-%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != uint64(i) {
+			t.Fatal("expect offset ", i, " but get ", offset)
+		}
+	}
+}
+
+func expectMessage(t *testing.T, q *diskqueue.Queue, offset int) diskqueue.Message {
+	msg, err := q.DequeueTimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Offset != uint64(offset) || string(msg.Data) != fmt.Sprintf("msg-%d", offset) {
+		t.Fatal("expect ", offset, " but get ", msg.Offset, " ", string(msg.Data))
+	}
+	return msg
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestDiskQueue(t *testing.T) {
+	t.Run("fifo", func(t *testing.T) {
+		q, err := diskqueue.Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+
+		enqueueN(t, q, 0, 10)
+		if q.Len() != 10 {
+			t.Fatal("expect 10 but get ", q.Len())
+		}
+		for i := 0; i < 10; i++ {
+			expectMessage(t, q, i)
+		}
+		if _, ok, err := q.TryDequeue(); ok || err != nil {
+			t.Fatal("expect empty queue ", err)
+		}
+		if _, err := q.DequeueTimeout(10 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+	})
+
+	t.Run("ack", func(t *testing.T) {
+		q, err := diskqueue.Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+
+		enqueueN(t, q, 0, 5)
+		if err := q.Ack(0); err != diskqueue.ErrInvalidOffset {
+			t.Fatal("expect ErrInvalidOffset but get ", err)
+		}
+		for i := 0; i < 4; i++ {
+			expectMessage(t, q, i)
+		}
+		for _, o := range []uint64{2, 1, 3} {
+			if err := q.Ack(o); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if q.Committed() != 0 {
+			t.Fatal("expect 0 but get ", q.Committed())
+		}
+		if err := q.Ack(0); err != nil {
+			t.Fatal(err)
+		}
+		if q.Committed() != 4 {
+			t.Fatal("expect 4 but get ", q.Committed())
+		}
+		expectMessage(t, q, 4)
+		if err := q.Commit(4); err != nil {
+			t.Fatal(err)
+		}
+		if q.Committed() != 5 {
+			t.Fatal("expect 5 but get ", q.Committed())
+		}
+	})
+
+	t.Run("rotate and cleanup", func(t *testing.T) {
+		dir := t.TempDir()
+		// 每条记录8字节头+5字节数据，每个段文件保存3条
+		q, err := diskqueue.Open(dir, diskqueue.OptSegmentSize(40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+
+		enqueueN(t, q, 0, 10)
+		if n := len(segmentFiles(t, dir)); n != 4 {
+			t.Fatal("expect 4 segments but get ", n)
+		}
+		for i := 0; i < 7; i++ {
+			expectMessage(t, q, i)
+		}
+		if err := q.Commit(6); err != nil {
+			t.Fatal(err)
+		}
+		files := segmentFiles(t, dir)
+		if len(files) != 2 || filepath.Base(files[0]) != fmt.Sprintf("%020d.seg", 6) {
+			t.Fatal("expect segments from 6 but get ", files)
+		}
+		for i := 7; i < 10; i++ {
+			expectMessage(t, q, i)
+		}
+		if err := q.Commit(9); err != nil {
+			t.Fatal(err)
+		}
+		// 当前写入的段文件不会被删除
+		if n := len(segmentFiles(t, dir)); n != 1 {
+			t.Fatal("expect 1 segment but get ", n)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		dir := t.TempDir()
+		q, err := diskqueue.Open(dir, diskqueue.OptSegmentSize(40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueueN(t, q, 0, 8)
+		for i := 0; i < 6; i++ {
+			expectMessage(t, q, i)
+		}
+		q.Ack(0)
+		q.Ack(1)
+		q.Ack(3)
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Enqueue([]byte("x")); err != diskqueue.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+
+		q, err = diskqueue.Open(dir, diskqueue.OptSegmentSize(40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		if q.Committed() != 2 || q.Len() != 6 {
+			t.Fatal("expect committed 2 len 6 but get ", q.Committed(), " ", q.Len())
+		}
+		for i := 2; i < 8; i++ {
+			expectMessage(t, q, i)
+		}
+		enqueueN(t, q, 8, 10)
+		expectMessage(t, q, 8)
+		expectMessage(t, q, 9)
+	})
+
+	t.Run("torn tail", func(t *testing.T) {
+		dir := t.TempDir()
+		q, err := diskqueue.Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueueN(t, q, 0, 3)
+		q.Close()
+
+		files := segmentFiles(t, dir)
+		f, err := os.OpenFile(files[len(files)-1], os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 模拟写入一半时崩溃
+		f.Write([]byte{0, 0, 0, 20, 1, 2, 3, 4, 'm', 's'})
+		f.Close()
+
+		q, err = diskqueue.Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		if q.Len() != 3 {
+			t.Fatal("expect 3 but get ", q.Len())
+		}
+		enqueueN(t, q, 3, 5)
+		for i := 0; i < 5; i++ {
+			expectMessage(t, q, i)
+		}
+	})
+
+	t.Run("corrupt tail", func(t *testing.T) {
+		dir := t.TempDir()
+		q, err := diskqueue.Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueueN(t, q, 0, 3)
+		q.Close()
+
+		files := segmentFiles(t, dir)
+		data, err := os.ReadFile(files[len(files)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 破坏最后一条消息的数据，损坏的记录不能被当作不完整的记录截断
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(files[len(files)-1], data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = diskqueue.Open(dir)
+		var corrupt *diskqueue.CorruptError
+		if !errors.As(err, &corrupt) || !errors.Is(err, diskqueue.ErrCorrupt) || corrupt.Offset != 2 {
+			t.Fatal("expect corrupt error at 2 but get ", err)
+		}
+		if info, _ := os.Stat(files[len(files)-1]); info.Size() != int64(len(data)) {
+			t.Fatal("corrupt segment must not be truncated")
+		}
+	})
+
+	t.Run("skip corrupt segment", func(t *testing.T) {
+		dir := t.TempDir()
+		// 每个段文件3条消息
+		q, err := diskqueue.Open(dir, diskqueue.OptSegmentSize(40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueueN(t, q, 0, 9)
+		q.Close()
+
+		files := segmentFiles(t, dir)
+		if len(files) != 3 {
+			t.Fatal("expect 3 segments but get ", files)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 破坏第一个段文件中的第二条消息
+		data[25] ^= 0xff
+		if err := os.WriteFile(files[0], data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		q, err = diskqueue.Open(dir, diskqueue.OptSegmentSize(40))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		m := expectMessage(t, q, 0)
+		for i := 0; i < 2; i++ {
+			_, err := q.DequeueTimeout(time.Second)
+			var corrupt *diskqueue.CorruptError
+			if !errors.As(err, &corrupt) || !errors.Is(err, diskqueue.ErrCorrupt) || corrupt.Offset != 1 {
+				t.Fatal("expect corrupt error at 1 but get ", err)
+			}
+		}
+		if n, err := q.SkipSegment(); err != nil || n != 2 {
+			t.Fatal("expect skip 2 but get ", n, err)
+		}
+		q.Ack(m.Offset)
+		m = expectMessage(t, q, 3)
+		q.Ack(m.Offset)
+		if q.Committed() != 4 {
+			t.Fatal("expect committed 4 but get ", q.Committed())
+		}
+		if files := segmentFiles(t, dir); len(files) != 2 {
+			t.Fatal("expect first segment removed but get ", files)
+		}
+
+		// 当前写入的段文件中的消息损坏
+		expectMessage(t, q, 4)
+		expectMessage(t, q, 5)
+		files = segmentFiles(t, dir)
+		data, err = os.ReadFile(files[len(files)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[12] ^= 0xff
+		if err := os.WriteFile(files[len(files)-1], data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.DequeueTimeout(time.Second); !errors.Is(err, diskqueue.ErrCorrupt) {
+			t.Fatal("expect corrupt error but get ", err)
+		}
+		if n, err := q.SkipSegment(); err != nil || n != 3 {
+			t.Fatal("expect skip 3 but get ", n, err)
+		}
+		enqueueN(t, q, 9, 10)
+		expectMessage(t, q, 9)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		dir := t.TempDir()
+		q, err := diskqueue.Open(dir, diskqueue.OptSegmentSize(40), diskqueue.OptSync(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		enqueueN(t, q, 0, 9)
+		for i := 0; i < 5; i++ {
+			m := expectMessage(t, q, i)
+			if err := q.Ack(m.Offset); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		q, err = diskqueue.Open(dir, diskqueue.OptSegmentSize(40), diskqueue.OptSync(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		if q.Committed() != 5 || q.Len() != 4 {
+			t.Fatal("expect committed 5 and 4 left but get ", q.Committed(), q.Len())
+		}
+		for i := 5; i < 9; i++ {
+			expectMessage(t, q, i)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		q, err := diskqueue.Open(t.TempDir(), diskqueue.OptMaxMessageSize(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		if _, err := q.Enqueue([]byte("12345")); err != diskqueue.ErrTooLarge {
+			t.Fatal("expect ErrTooLarge but get ", err)
+		}
+	})
+
+	t.Run("blocking", func(t *testing.T) {
+		q, err := diskqueue.Open(t.TempDir(), diskqueue.OptSync(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			q.Enqueue([]byte("msg-0"))
+		}()
+		msg, err := q.Dequeue()
+		if err != nil || msg.Offset != 0 {
+			t.Fatal(msg, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+		if _, err := q.DequeueContext(ctx); err != context.Canceled {
+			t.Fatal("expect Canceled but get ", err)
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			q.Close()
+		}()
+		if _, err := q.Dequeue(); err != diskqueue.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+	})
+}
@@ -88,3 +88,93 @@ func testMap(t *testing.T, m xmap.Map) {
 		t.Fatal("must 1 but: ", m.Size())
 	}
 }
+
+func TestMapOf(t *testing.T) {
+	m := xmap.NewSimpleMapOf[int, string]()
+	testMapOf(t, m)
+}
+
+func TestSimpleLinkedMapOf(t *testing.T) {
+	m := xmap.NewSimpleLinkedMapOf[int, string]()
+	testMapOf(t, m)
+	testLinkedMapOfOrder(t, m)
+}
+
+func TestLinkedMapOf(t *testing.T) {
+	m := xmap.NewLinkedMapOf[int, string]()
+	testMapOf(t, m)
+	testLinkedMapOfOrder(t, m)
+}
+
+func testLinkedMapOfOrder(t *testing.T, m xmap.MapOf[int, string]) {
+	m.Put(3, "c")
+	m.Put(4, "d")
+	// 已存在的key更新后保持原有顺序
+	m.Put(3, "cc")
+	m.Delete(4)
+	m.Put(4, "dd")
+
+	expect := [][2]interface{}{{1, "a"}, {3, "cc"}, {4, "dd"}}
+	i := 0
+	m.Foreach(func(key int, value string) bool {
+		if key != expect[i][0].(int) || value != expect[i][1].(string) {
+			t.Fatal("key ", key, " value ", value, "not match")
+		}
+		i++
+		return true
+	})
+	if i != len(expect) {
+		t.Fatal("expect ", len(expect), " but get ", i)
+	}
+
+	m.Foreach(func(key int, value string) bool {
+		if key != 1 || value != "a" {
+			t.Fatal("key ", key, " value ", value, "not match")
+		}
+		return false
+	})
+}
+
+func testMapOf(t *testing.T, m xmap.MapOf[int, string]) {
+	if _, ok := m.Get(1); ok {
+		t.Fatal("key 1 have no value ")
+	}
+
+	m.Put(1, "a")
+	if _, ok := m.Get(2); ok {
+		t.Fatal("key 2 have no value ")
+	}
+	if v, ok := m.Get(1); !ok || v != "a" {
+		t.Fatal("not exits, v: ", v)
+	}
+
+	v, load := m.GetOrPut(1, "x")
+	if !load {
+		t.Fatal("must loaded")
+	}
+	if v != "a" {
+		t.Fatal("must be a but: ", v)
+	}
+
+	v, load = m.GetOrPut(2, "b")
+	if load {
+		t.Fatal("must not loaded")
+	}
+	if v != "b" {
+		t.Fatal("must be b but: ", v)
+	}
+
+	m.Foreach(func(key int, value string) bool {
+		t.Log("key ", key, " value ", value)
+		return true
+	})
+
+	m.Delete(2)
+	if _, ok := m.Get(2); ok {
+		t.Fatal("key 2 must be deleted")
+	}
+
+	if m.Size() != 1 {
+		t.Fatal("must 1 but: ", m.Size())
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"github.com/xfali/goutils/v2/container/lru"
+	"math/rand"
+	"testing"
+)
+
+func policies(capacity int) map[string]lru.LRU {
+	return map[string]lru.LRU{
+		"LRU":     lru.NewLruCache(capacity),
+		"LFU":     lru.NewLfuCache(capacity),
+		"ARC":     lru.NewArcCache(capacity),
+		"2Q":      lru.New2QCache(capacity),
+		"TinyLFU": lru.NewTinyLfuCache(capacity),
+	}
+}
+
+func TestCachePolicies(t *testing.T) {
+	for name, m := range policies(10) {
+		if name == "LRU" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			testPolicy(t, m, 10)
+		})
+	}
+}
+
+func testPolicy(t *testing.T, m lru.LRU, capacity int) {
+	if _, ok := m.Get(1); ok {
+		t.Fatal("key 1 have no value ")
+	}
+	m.Put(1, "a")
+	if v, ok := m.Get(1); !ok || v.(string) != "a" {
+		t.Fatal("not exits, v: ", v)
+	}
+	m.Put(1, "aa")
+	if v, ok := m.Get(1); !ok || v.(string) != "aa" {
+		t.Fatal("not exits, v: ", v)
+	}
+	m.Put(2, "b")
+	if m.Size() != 2 {
+		t.Fatal("must 2 but: ", m.Size())
+	}
+	m.Delete(2)
+	if _, ok := m.Get(2); ok {
+		t.Fatal("2 must be deleted")
+	}
+	if m.Size() != 1 {
+		t.Fatal("must 1 but: ", m.Size())
+	}
+
+	for i := 0; i < capacity*10; i++ {
+		m.Put(i, i)
+		m.Get(i)
+		if m.Size() > capacity {
+			t.Fatal("size ", m.Size(), " over capacity ", capacity)
+		}
+	}
+
+	m.Purge()
+	if m.Size() != 0 {
+		t.Fatal("must be empty but: ", m.Size())
+	}
+	m.Put(1, "a")
+	if _, ok := m.Get(1); !ok {
+		t.Fatal("cache must be usable after purge")
+	}
+}
+
+func TestLFU(t *testing.T) {
+	m := lru.NewLfuCache(2)
+	m.Put(1, "a")
+	m.Put(2, "b")
+	m.Get(1)
+	// 2 访问频率最低，被淘汰
+	m.Put(3, "c")
+	if _, ok := m.Get(2); ok {
+		t.Fatal("2 must be eliminated")
+	}
+	m.Get(3)
+	m.Get(3)
+	// 1、3 频率都为2以上，1 最久未访问，被淘汰
+	m.Put(4, "d")
+	if _, ok := m.Get(1); ok {
+		t.Fatal("1 must be eliminated")
+	}
+	if _, ok := m.Get(3); !ok {
+		t.Fatal("3 must exist")
+	}
+}
+
+// 热点数据与冷数据交替访问多次后进行一次大范围扫描，抗扫描的策略应当保留热点数据
+func TestCachePolicyScanResistant(t *testing.T) {
+	for name, m := range policies(100) {
+		if name == "LRU" || name == "LFU" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			access := func(k int) {
+				if _, ok := m.Get(k); !ok {
+					m.Put(k, k)
+				}
+			}
+			cold := 1000
+			for n := 0; n < 3; n++ {
+				for i := 0; i < 50; i++ {
+					access(i)
+				}
+				for i := 0; i < 50; i++ {
+					access(cold)
+					cold++
+				}
+			}
+			for i := 0; i < 1000; i++ {
+				access(cold)
+				cold++
+			}
+			hits := 0
+			for i := 0; i < 50; i++ {
+				if _, ok := m.Get(i); ok {
+					hits++
+				}
+			}
+			if hits < 40 {
+				t.Fatal("expect hot keys survive the scan but only hit ", hits)
+			}
+		})
+	}
+}
+
+// zipf分布的访问序列，每隔scanInterval次访问插入一次长度为scanLen的顺序扫描
+func zipfTrace(n, keys, scanInterval, scanLen int) []int {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.1, 1, uint64(keys-1))
+	ret := make([]int, 0, n)
+	scanKey := keys
+	for len(ret) < n {
+		if scanInterval > 0 && len(ret) > 0 && len(ret)%scanInterval == 0 {
+			for i := 0; i < scanLen && len(ret) < n; i++ {
+				ret = append(ret, scanKey)
+				scanKey++
+			}
+		}
+		ret = append(ret, int(z.Uint64()))
+	}
+	return ret
+}
+
+func hitRatio(m lru.LRU, trace []int) float64 {
+	hits := 0
+	for _, k := range trace {
+		if _, ok := m.Get(k); ok {
+			hits++
+		} else {
+			m.Put(k, k)
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func TestCachePolicyHitRatio(t *testing.T) {
+	trace := zipfTrace(200000, 100000, 10000, 5000)
+	lruRatio := hitRatio(lru.NewLruCache(1000), trace)
+	for name, m := range policies(1000) {
+		ratio := hitRatio(m, trace)
+		t.Logf("%s hit ratio: %.4f", name, ratio)
+		if name != "LRU" && name != "LFU" && ratio < lruRatio {
+			t.Fatalf("%s hit ratio %.4f lower than LRU %.4f", name, ratio, lruRatio)
+		}
+	}
+}
+
+func benchmarkPolicy(b *testing.B, create func(capacity int) lru.LRU, scanInterval int) {
+	trace := zipfTrace(1<<20, 100000, scanInterval, 5000)
+	m := create(1000)
+	hits := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := trace[i&(len(trace)-1)]
+		if _, ok := m.Get(k); ok {
+			hits++
+		} else {
+			m.Put(k, k)
+		}
+	}
+	b.ReportMetric(float64(hits)*100/float64(b.N), "hit%")
+}
+
+func BenchmarkZipfLRU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewLruCache(c) }, 0)
+}
+
+func BenchmarkZipfLFU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewLfuCache(c) }, 0)
+}
+
+func BenchmarkZipfARC(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewArcCache(c) }, 0)
+}
+
+func BenchmarkZipf2Q(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.New2QCache(c) }, 0)
+}
+
+func BenchmarkZipfTinyLFU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewTinyLfuCache(c) }, 0)
+}
+
+func BenchmarkZipfScanLRU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewLruCache(c) }, 10000)
+}
+
+func BenchmarkZipfScanLFU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewLfuCache(c) }, 10000)
+}
+
+func BenchmarkZipfScanARC(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewArcCache(c) }, 10000)
+}
+
+func BenchmarkZipfScan2Q(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.New2QCache(c) }, 10000)
+}
+
+func BenchmarkZipfScanTinyLFU(b *testing.B) {
+	benchmarkPolicy(b, func(c int) lru.LRU { return lru.NewTinyLfuCache(c) }, 10000)
+}
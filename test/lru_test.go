@@ -6,8 +6,17 @@
 package test
 
 import (
+	"errors"
+	"fmt"
 	"github.com/xfali/goutils/v2/container/lru"
+	"github.com/xfali/goutils/v2/container/purger"
+	"github.com/xfali/goutils/v2/container/xmap"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSimpleLRU(t *testing.T) {
@@ -62,6 +71,144 @@ func testLru(t *testing.T, m lru.LRU) {
 	}
 }
 
+type ttlLru interface {
+	lru.LRU
+	PutWithTTL(key, value interface{}, ttl time.Duration)
+	RemoveExpired() int
+}
+
+func TestLruTTL(t *testing.T) {
+	for name, m := range map[string]ttlLru{
+		"LRU":  lru.NewLruCacheWithOpts(10, lru.OptLruDefaultTTL(20*time.Millisecond)),
+		"LRUK": lru.NewLruKCacheWithOpts(2, 10, 10, lru.OptLruDefaultTTL(20*time.Millisecond)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer m.Purge()
+			m.Put(1, "a")
+			m.Put(2, "b")
+			m.PutWithTTL(3, "c", -1)
+			m.PutWithTTL(4, "d", time.Hour)
+			if v, ok := m.Get(1); !ok || v != "a" {
+				t.Fatal("expect a but get ", v)
+			}
+			time.Sleep(30 * time.Millisecond)
+			// 访问时被动移除
+			if v, ok := m.Get(1); ok {
+				t.Fatal("expect 1 expired but get ", v)
+			}
+			if m.Size() != 3 {
+				t.Fatal("expect 3 but get ", m.Size())
+			}
+			if n := m.RemoveExpired(); n != 1 || m.Size() != 2 {
+				t.Fatal("expect remove 1 but get ", n, m.Size())
+			}
+			for _, k := range []int{3, 4} {
+				if _, ok := m.Get(k); !ok {
+					t.Fatal("expect ", k, " exists")
+				}
+			}
+			// 覆盖后重新计算过期时间
+			m.PutWithTTL(3, "c", 10*time.Millisecond)
+			m.Put(3, "cc")
+			time.Sleep(15 * time.Millisecond)
+			if v, ok := m.Get(3); !ok || v != "cc" {
+				t.Fatal("expect cc but get ", v)
+			}
+		})
+	}
+}
+
+func TestLruWeigher(t *testing.T) {
+	weigher := lru.OptLruWeigher(func(key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+
+	m := lru.NewLruCacheWithOpts(10, weigher)
+	defer m.Purge()
+	m.Put(1, "aaaa")
+	m.Put(2, "bbbb")
+	if m.Weight() != 8 {
+		t.Fatal("expect 8 but get ", m.Weight())
+	}
+	m.Get(1)
+	// 淘汰最久未访问的2
+	m.Put(3, "ccc")
+	if _, ok := m.Get(2); ok || m.Weight() != 7 || m.Size() != 2 {
+		t.Fatal("expect 2 evicted but get ", m.Weight(), m.Size())
+	}
+	// 覆盖已存在的key时重新计算权重，持续淘汰直到总权重不超过容量
+	m.Put(3, "cccccccc")
+	if _, ok := m.Get(1); ok || m.Weight() != 8 || m.Size() != 1 {
+		t.Fatal("expect 1 evicted but get ", m.Weight(), m.Size())
+	}
+	// 单个元素超出容量时只保留该元素
+	m.Put(4, "dddddddddddd")
+	if v, ok := m.Get(4); !ok || v != "dddddddddddd" || m.Size() != 1 || m.Weight() != 12 {
+		t.Fatal("expect only 4 left but get ", m.Weight(), m.Size())
+	}
+	m.Delete(4)
+	if m.Weight() != 0 {
+		t.Fatal("expect 0 but get ", m.Weight())
+	}
+
+	k := lru.NewLruKCacheWithOpts(2, 10, 10, weigher)
+	defer k.Purge()
+	k.Put(1, "aaaaaa")
+	k.Put(2, "bbbbbb")
+	if _, ok := k.Get(1); ok {
+		t.Fatal("expect 1 evicted from history")
+	}
+	k.Get(2)
+	k.Get(2)
+	// 访问k次后从历史队列移动到缓存队列
+	if h, c := k.Weight(); h != 0 || c != 6 {
+		t.Fatal("expect 2 moved to cache but get ", h, c)
+	}
+	k.Delete(2)
+	if h, c := k.Weight(); h != 0 || c != 0 || k.Size() != 0 {
+		t.Fatal("expect empty but get ", h, c, k.Size())
+	}
+}
+
+func TestSyncLru(t *testing.T) {
+	e := purger.New()
+	defer e.Close()
+
+	for name, l := range map[string]lru.ExpirableLRU{
+		"simple": lru.NewLruCacheWithOpts(100, lru.OptLruDefaultTTL(5*time.Millisecond)),
+		"lruk":   lru.NewLruKCacheWithOpts(2, 100, 100, lru.OptLruDefaultTTL(5*time.Millisecond)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := lru.NewSyncLru(l, lru.OptSyncLruAutoPurge(5*time.Millisecond, e))
+			wait := sync.WaitGroup{}
+			for w := 0; w < 4; w++ {
+				wait.Add(1)
+				go func(w int) {
+					defer wait.Done()
+					for i := 0; i < 50; i++ {
+						c.Put(w*100+i, i)
+						c.Get(w*100 + i)
+					}
+				}(w)
+			}
+			wait.Wait()
+			c.PutWithTTL("forever", 1, -1)
+			// 过期元素不访问也会被后台清理
+			time.Sleep(50 * time.Millisecond)
+			if c.Size() != 1 {
+				t.Fatal("expect expired removed but get ", c.Size())
+			}
+			if v, ok := c.Get("forever"); !ok || v != 1 {
+				t.Fatal("expect forever=1 but get ", v, ok)
+			}
+			c.Purge()
+			if n := c.RemoveExpired(); n != 0 {
+				t.Fatal("expect 0 after purge but get ", n)
+			}
+		})
+	}
+}
+
 func TestLRUK(t *testing.T) {
 	lru := lru.NewLruKCache(2, 3, 3)
 	testLruk(t, lru)
@@ -159,3 +306,409 @@ func testLruk(t *testing.T, m lru.LRU) {
 		t.Fatal("1 must not exits, v: ", v)
 	}
 }
+
+func TestCache(t *testing.T) {
+	var evicted []string
+	c := lru.NewCache[int, string](3, lru.OptOnEvict(func(key int, value string, reason lru.EvictReason) {
+		evicted = append(evicted, fmt.Sprintf("%d:%s:%s", key, value, reason))
+	}))
+	var m xmap.IMapOf[int, string] = c
+	m.Put(1, "a")
+	m.Put(2, "b")
+	m.Put(3, "c")
+	if v, ok := m.Get(1); !ok || v != "a" {
+		t.Fatal("not exits, v: ", v)
+	}
+	// 2 eliminated
+	m.Put(4, "d")
+	if _, ok := m.Get(2); ok {
+		t.Fatal("2 must be eliminated")
+	}
+	m.Put(3, "cc")
+	m.Delete(3)
+	if m.Size() != 2 {
+		t.Fatal("must 2 but: ", m.Size())
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("stats not match: %+v", stats)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Fatal("expect 0.5 but get ", stats.HitRatio())
+	}
+
+	c.Clear()
+	if c.Size() != 0 {
+		t.Fatal("must be empty")
+	}
+	expect := "[2:b:capacity 3:cc:deleted 1:a:cleared 4:d:cleared]"
+	if fmt.Sprint(evicted) != expect {
+		t.Fatal("expect ", expect, " but get ", evicted)
+	}
+}
+
+func TestCacheEvictCallbackReentrant(t *testing.T) {
+	var c *lru.Cache[int, int]
+	c = lru.NewCache[int, int](1, lru.OptOnEvict(func(key int, value int, reason lru.EvictReason) {
+		// 回调在锁外执行，可以访问缓存
+		c.Peek(key)
+	}))
+	c.Put(1, 1)
+	c.Put(2, 2)
+	if _, ok := c.Peek(1); ok {
+		t.Fatal("1 must be eliminated")
+	}
+}
+
+func TestCacheSharded(t *testing.T) {
+	c := lru.NewCache[string, int](1024, lru.OptShards[string, int](16, nil))
+	wait := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wait.Add(1)
+		go func(w int) {
+			defer wait.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(i % 200)
+				c.Put(key, i)
+				c.Get(key)
+				if i%7 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(w)
+	}
+	wait.Wait()
+	stats := c.Stats()
+	if stats.Size != c.Size() || stats.Size > 200 {
+		t.Fatalf("stats not match: %+v", stats)
+	}
+	if stats.Hits+stats.Misses != 8000 {
+		t.Fatalf("expect 8000 gets but: %+v", stats)
+	}
+}
+
+func TestCacheShardedKeys(t *testing.T) {
+	t.Run("pointer", func(t *testing.T) {
+		type node struct{ v int }
+		c := lru.NewCache[*node, int](1024, lru.OptShards[*node, int](16, nil))
+		nodes := make([]*node, 100)
+		for i := range nodes {
+			nodes[i] = &node{}
+			c.Put(nodes[i], i)
+		}
+		// 修改指针指向的内容不影响key所在的分片
+		for i, n := range nodes {
+			n.v = i + 1
+			if v, ok := c.Get(n); !ok || v != i {
+				t.Fatal("expect ", i, " but get ", v, ok)
+			}
+		}
+	})
+
+	t.Run("float", func(t *testing.T) {
+		c := lru.NewCache[float64, int](1024, lru.OptShards[float64, int](16, nil))
+		c.Put(0.0, 1)
+		if v, ok := c.Get(math.Copysign(0, -1)); !ok || v != 1 {
+			t.Fatal("expect -0.0 equals 0.0 but get ", v, ok)
+		}
+		c.Put(math.Copysign(0, -1), 2)
+		if c.Size() != 1 {
+			t.Fatal("expect 1 but get ", c.Size())
+		}
+	})
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Run("lazy expire", func(t *testing.T) {
+		var reasons []lru.EvictReason
+		c := lru.NewCache[int, string](10,
+			lru.OptDefaultTTL[int, string](50*time.Millisecond),
+			lru.OptOnEvict(func(key int, value string, reason lru.EvictReason) {
+				reasons = append(reasons, reason)
+			}))
+		c.Put(1, "a")
+		c.PutWithTTL(2, "b", -1)
+		if ttl := c.TTL(1); ttl <= 0 || ttl > 50*time.Millisecond {
+			t.Fatal("ttl not match: ", ttl)
+		}
+		if ttl := c.TTL(2); ttl != -1 {
+			t.Fatal("expect -1 but get ", ttl)
+		}
+		if v, ok := c.Get(1); !ok || v != "a" {
+			t.Fatal("1 must exist")
+		}
+		time.Sleep(60 * time.Millisecond)
+		if _, ok := c.Peek(1); ok {
+			t.Fatal("1 must be expired")
+		}
+		if c.TTL(1) != -2 {
+			t.Fatal("expect -2 but get ", c.TTL(1))
+		}
+		if _, ok := c.Get(1); ok {
+			t.Fatal("1 must be expired")
+		}
+		if _, ok := c.Get(2); !ok {
+			t.Fatal("2 must exist")
+		}
+		stats := c.Stats()
+		if stats.Expirations != 1 || stats.Size != 1 || stats.Misses != 1 {
+			t.Fatalf("stats not match: %+v", stats)
+		}
+		if len(reasons) != 1 || reasons[0] != lru.EvictReasonExpired {
+			t.Fatal("expect expired callback but get ", reasons)
+		}
+	})
+
+	t.Run("auto purge", func(t *testing.T) {
+		c := lru.NewCache[int, string](10,
+			lru.OptAutoPurge[int, string](20*time.Millisecond, nil))
+		c.PutWithTTL(1, "a", 10*time.Millisecond)
+		c.PutWithTTL(2, "b", time.Hour)
+		c.Put(3, "c")
+		time.Sleep(200 * time.Millisecond)
+		if c.Size() != 2 {
+			t.Fatal("expect 2 but get ", c.Size())
+		}
+		if c.Stats().Expirations != 1 {
+			t.Fatal("expect 1 expiration")
+		}
+	})
+
+	t.Run("manual purge", func(t *testing.T) {
+		c := lru.NewCache[int, string](100, lru.OptShards[int, string](4, nil))
+		for i := 0; i < 10; i++ {
+			c.PutWithTTL(i, "v", time.Duration(i%2)*time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+		c.Purge()
+		if c.Size() != 5 {
+			t.Fatal("expect 5 but get ", c.Size())
+		}
+	})
+}
+
+func TestCacheGetOrLoad(t *testing.T) {
+	t.Run("singleflight", func(t *testing.T) {
+		c := lru.NewCache[int, string](10)
+		var calls int32
+		release := make(chan struct{})
+		loader := func(key int) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return strconv.Itoa(key), nil
+		}
+		wait := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				v, err := c.GetOrLoad(1, loader)
+				if err != nil || v != "1" {
+					t.Error("load failed: ", v, err)
+				}
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wait.Wait()
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Fatal("expect 1 load but get ", n)
+		}
+		if v, ok := c.Get(1); !ok || v != "1" {
+			t.Fatal("loaded value must be cached")
+		}
+		if stats := c.Stats(); stats.Loads != 1 || stats.LoadErrors != 0 {
+			t.Fatalf("stats not match: %+v", stats)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		c := lru.NewCache[int, string](10, lru.OptNegativeTTL[int, string](50*time.Millisecond))
+		calls := 0
+		loadErr := errors.New("load error")
+		loader := func(key int) (string, error) {
+			calls++
+			if calls == 1 {
+				return "", loadErr
+			}
+			return "ok", nil
+		}
+		if _, err := c.GetOrLoad(1, loader); err != loadErr {
+			t.Fatal("expect load error but get ", err)
+		}
+		if _, err := c.GetOrLoad(1, loader); err != loadErr || calls != 1 {
+			t.Fatal("error must be cached, calls: ", calls)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if v, err := c.GetOrLoad(1, loader); err != nil || v != "ok" || calls != 2 {
+			t.Fatal("must reload after negative ttl: ", v, err, calls)
+		}
+		if stats := c.Stats(); stats.Loads != 2 || stats.LoadErrors != 1 {
+			t.Fatalf("stats not match: %+v", stats)
+		}
+
+		c.GetOrLoad(2, func(key int) (string, error) {
+			return "", loadErr
+		})
+		c.Put(2, "put")
+		if v, err := c.GetOrLoad(2, loader); err != nil || v != "put" {
+			t.Fatal("put must clear cached error: ", v, err)
+		}
+	})
+
+	t.Run("refresh ahead", func(t *testing.T) {
+		c := lru.NewCache[int, int](10,
+			lru.OptDefaultTTL[int, int](100*time.Millisecond),
+			lru.OptRefreshAhead[int, int](60*time.Millisecond))
+		var version int32
+		loader := func(key int) (int, error) {
+			return int(atomic.AddInt32(&version, 1)), nil
+		}
+		if v, _ := c.GetOrLoad(1, loader); v != 1 {
+			t.Fatal("expect 1 but get ", v)
+		}
+		if v, _ := c.GetOrLoad(1, loader); v != 1 || atomic.LoadInt32(&version) != 1 {
+			t.Fatal("must not refresh before refresh ahead window")
+		}
+		time.Sleep(50 * time.Millisecond)
+		// 进入提前刷新窗口，返回旧值并异步刷新
+		if v, _ := c.GetOrLoad(1, loader); v != 1 {
+			t.Fatal("expect 1 but get ", v)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if v, ok := c.Get(1); !ok || v != 2 {
+			t.Fatal("expect refreshed value 2 but get ", v)
+		}
+		if ttl := c.TTL(1); ttl < 70*time.Millisecond {
+			t.Fatal("ttl must be reset by refresh: ", ttl)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		c := lru.NewCache[int, string](10)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		loader := func(key int) (string, error) {
+			close(started)
+			<-release
+			panic("boom")
+		}
+		recovered := make(chan interface{})
+		go func() {
+			defer func() {
+				recovered <- recover()
+			}()
+			c.GetOrLoad(1, loader)
+		}()
+		<-started
+		waitErr := make(chan error)
+		go func() {
+			_, err := c.GetOrLoad(1, loader)
+			waitErr <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		if r := <-recovered; r != "boom" {
+			t.Fatal("loader caller must panic but get ", r)
+		}
+		if err := <-waitErr; !errors.Is(err, lru.ErrLoaderPanic) {
+			t.Fatal("waiting caller expect ErrLoaderPanic but get ", err)
+		}
+		if v, err := c.GetOrLoad(1, func(key int) (string, error) {
+			return "ok", nil
+		}); err != nil || v != "ok" {
+			t.Fatal("load after panic failed: ", v, err)
+		}
+		if stats := c.Stats(); stats.Loads != 2 || stats.LoadErrors != 1 {
+			t.Fatalf("stats not match: %+v", stats)
+		}
+	})
+
+	t.Run("delete during load", func(t *testing.T) {
+		c := lru.NewCache[int, string](10)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			v, err := c.GetOrLoad(1, func(key int) (string, error) {
+				close(started)
+				<-release
+				return "stale", nil
+			})
+			if err != nil || v != "stale" {
+				t.Error("loader caller must get loaded value: ", v, err)
+			}
+		}()
+		<-started
+		c.Delete(1)
+		close(release)
+		<-done
+		if v, ok := c.Get(1); ok {
+			t.Fatal("stale value must not be cached: ", v)
+		}
+
+		started = make(chan struct{})
+		release = make(chan struct{})
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			c.GetOrLoad(2, func(key int) (string, error) {
+				close(started)
+				<-release
+				return "stale", nil
+			})
+		}()
+		<-started
+		c.Put(2, "put")
+		close(release)
+		<-done
+		if v, _ := c.Get(2); v != "put" {
+			t.Fatal("load must not overwrite put value: ", v)
+		}
+	})
+}
+
+func TestCacheWeigher(t *testing.T) {
+	var evicted []string
+	c := lru.NewCache[string, []byte](10,
+		lru.OptWeigher(func(key string, value []byte) int64 {
+			return int64(len(value))
+		}),
+		lru.OptOnEvict(func(key string, value []byte, reason lru.EvictReason) {
+			evicted = append(evicted, key)
+		}))
+	c.Put("a", make([]byte, 3))
+	c.Put("b", make([]byte, 3))
+	c.Put("c", make([]byte, 3))
+	if stats := c.Stats(); stats.Weight != 9 || stats.Size != 3 {
+		t.Fatalf("stats not match: %+v", stats)
+	}
+	c.Get("a")
+	// 总权重14超出容量10，依次淘汰最久未访问的b、c
+	c.Put("d", make([]byte, 5))
+	if fmt.Sprint(evicted) != "[b c]" {
+		t.Fatal("expect [b c] evicted but get ", evicted)
+	}
+	if stats := c.Stats(); stats.Weight != 8 || stats.Size != 2 {
+		t.Fatalf("stats not match: %+v", stats)
+	}
+
+	// 更新元素权重同样触发淘汰
+	c.Put("d", make([]byte, 9))
+	if _, ok := c.Peek("a"); ok {
+		t.Fatal("a must be evicted")
+	}
+	// 单个元素超出容量直接被淘汰
+	c.Put("e", make([]byte, 11))
+	if c.Size() != 0 || c.Stats().Weight != 0 {
+		t.Fatalf("cache must be empty: %+v", c.Stats())
+	}
+
+	c.Put("f", nil)
+	c.Delete("f")
+	if c.Stats().Weight != 0 {
+		t.Fatal("weight must be 0 after delete")
+	}
+}
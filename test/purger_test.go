@@ -0,0 +1,268 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"github.com/xfali/goutils/v2/container/lru"
+	"github.com/xfali/goutils/v2/container/purger"
+	"github.com/xfali/goutils/v2/container/recycleMap"
+	"github.com/xfali/timewheel"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countPurger struct {
+	count int32
+	panic bool
+}
+
+func (p *countPurger) Purge() {
+	atomic.AddInt32(&p.count, 1)
+	if p.panic {
+		panic("purge failed")
+	}
+}
+
+func (p *countPurger) get() int32 {
+	return atomic.LoadInt32(&p.count)
+}
+
+func TestPurgeExecutor(t *testing.T) {
+	t.Run("add remove", func(t *testing.T) {
+		e := purger.New()
+		defer e.Close()
+		p := &countPurger{}
+		if err := e.AddPurger(p, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.AddPurger(p, 10*time.Millisecond); err == nil {
+			t.Fatal("expect duplicate add error")
+		}
+		time.Sleep(55 * time.Millisecond)
+		if p.get() == 0 {
+			t.Fatal("expect purged")
+		}
+		stats, ok := e.Stats(p)
+		if !ok || stats.Runs == 0 || stats.LastRun.IsZero() || stats.Interval != 10*time.Millisecond {
+			t.Fatal("expect stats but get ", stats)
+		}
+		if err := e.RemovePurger(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.RemovePurger(p); err == nil {
+			t.Fatal("expect remove not found error")
+		}
+		if _, ok := e.Stats(p); ok {
+			t.Fatal("expect no stats after remove")
+		}
+		n := p.get()
+		time.Sleep(30 * time.Millisecond)
+		if p.get() != n {
+			t.Fatal("expect not purged after remove")
+		}
+		// 可以重新添加
+		if err := e.AddPurger(p, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("set interval", func(t *testing.T) {
+		e := purger.New()
+		defer e.Close()
+		p := &countPurger{}
+		e.AddPurger(p, time.Hour)
+		time.Sleep(30 * time.Millisecond)
+		if p.get() != 0 {
+			t.Fatal("expect not purged")
+		}
+		if err := e.SetInterval(p, 5*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if p.get() == 0 {
+			t.Fatal("expect purged after set interval")
+		}
+		if stats, _ := e.Stats(p); stats.Interval != 5*time.Millisecond || stats.Runs == 0 {
+			t.Fatal("expect stats kept with new interval but get ", stats)
+		}
+		if err := e.SetInterval(&countPurger{}, time.Second); err == nil {
+			t.Fatal("expect not found error")
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var lock sync.Mutex
+		var errs []error
+		e := purger.New(purger.Opts.ErrorHandler(func(p purger.Purger, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			errs = append(errs, err)
+		}))
+		p := &countPurger{panic: true}
+		e.AddPurger(p, 5*time.Millisecond)
+		time.Sleep(30 * time.Millisecond)
+		if p.get() < 2 {
+			t.Fatal("expect purge continue after panic")
+		}
+		stats, _ := e.Stats(p)
+		if stats.Panics == 0 || stats.Panics != stats.Runs {
+			t.Fatal("expect panics counted but get ", stats)
+		}
+		// Close时也会恢复panic
+		e.Close()
+		lock.Lock()
+		defer lock.Unlock()
+		if len(errs) < 2 {
+			t.Fatal("expect error handler called")
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		e := purger.New()
+		p := &countPurger{}
+		e.AddPurger(p, time.Hour)
+		e.Close()
+		if p.get() != 1 {
+			t.Fatal("expect purge once on close")
+		}
+		if err := e.AddPurger(p, time.Hour); err == nil {
+			t.Fatal("expect add error after close")
+		}
+	})
+}
+
+type recordTimer struct {
+	canceled int32
+}
+
+func (t *recordTimer) Cancel() {
+	atomic.StoreInt32(&t.canceled, 1)
+}
+
+// 只记录添加的定时器，不触发回调
+type recordTimeWheel struct {
+	lock   sync.Mutex
+	timers []*recordTimer
+}
+
+func (w *recordTimeWheel) Start() {}
+
+func (w *recordTimeWheel) Stop() {}
+
+func (w *recordTimeWheel) Add(f func(), d time.Duration, repeat bool) (timewheel.Timer, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	t := &recordTimer{}
+	w.timers = append(w.timers, t)
+	return t, nil
+}
+
+func (w *recordTimeWheel) canceled() []bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	ret := make([]bool, len(w.timers))
+	for i, t := range w.timers {
+		ret[i] = atomic.LoadInt32(&t.canceled) == 1
+	}
+	return ret
+}
+
+func TestPurgeExecutorCancelTimer(t *testing.T) {
+	tw := &recordTimeWheel{}
+	e := purger.New(purger.Opts.TimeWheel(tw))
+	defer e.Close()
+
+	p1, p2 := &countPurger{}, &countPurger{}
+	e.AddPurger(p1, time.Second)
+	e.AddPurger(p2, time.Second)
+	e.SetInterval(p1, 2*time.Second)
+	if c := tw.canceled(); len(c) != 3 || !c[0] || c[1] || c[2] {
+		t.Fatal("expect old timer canceled after SetInterval but get ", c)
+	}
+	e.RemovePurger(p2)
+	if c := tw.canceled(); !c[1] || c[2] {
+		t.Fatal("expect timer canceled after RemovePurger but get ", c)
+	}
+}
+
+func TestPurgeExecutorRemoveOnClose(t *testing.T) {
+	e := purger.New()
+	defer e.Close()
+
+	dm := recycleMap.New(recycleMap.OptAutoPurge[string, string](5*time.Millisecond, e))
+	sm := recycleMap.NewSharded(4, recycleMap.OptAutoPurge[string, string](5*time.Millisecond, e))
+	c := lru.NewCache(10, lru.OptAutoPurge[string, string](5*time.Millisecond, e))
+
+	dm.Set("a", "1", time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if len(dm.Keys("")) != 0 {
+		t.Fatal("expect auto purged")
+	}
+	stats, ok := purger.PurgeExecutor(e).(purger.StatsReporter)
+	if !ok {
+		t.Fatal("expect default executor implements StatsReporter")
+	}
+	for _, p := range []purger.Purger{dm.(purger.Purger), sm.(purger.Purger), c} {
+		if _, ok := stats.Stats(p); !ok {
+			t.Fatal("expect purger registered")
+		}
+	}
+	dm.Close()
+	sm.Close()
+	c.Close()
+	for _, p := range []purger.Purger{dm.(purger.Purger), sm.(purger.Purger), c} {
+		if _, ok := stats.Stats(p); ok {
+			t.Fatal("expect purger removed after close")
+		}
+	}
+	// 重复关闭
+	if err := dm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 只实现PurgeExecutor的执行器
+type addOnlyExecutor struct {
+	lock    sync.Mutex
+	purgers []purger.Purger
+}
+
+func (e *addOnlyExecutor) AddPurger(p purger.Purger, interval time.Duration) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.purgers = append(e.purgers, p)
+	return nil
+}
+
+func (e *addOnlyExecutor) Close() error {
+	return nil
+}
+
+func TestPurgeExecutorOptional(t *testing.T) {
+	e := &addOnlyExecutor{}
+	var pe purger.PurgeExecutor = e
+	if _, ok := pe.(purger.PurgerRemover); ok {
+		t.Fatal("expect not PurgerRemover")
+	}
+	dm := recycleMap.New(recycleMap.OptAutoPurge[string, string](time.Second, e))
+	sm := recycleMap.NewSharded(4, recycleMap.OptAutoPurge[string, string](time.Second, e))
+	c := lru.NewCache(10, lru.OptAutoPurge[string, string](time.Second, e))
+	if len(e.purgers) != 3 {
+		t.Fatal("expect 3 purgers but get ", len(e.purgers))
+	}
+	dm.Set("a", "1", -1)
+	c.Put("a", "1")
+	for _, closer := range []interface{ Close() error }{dm, sm, c} {
+		if err := closer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Size() != 1 {
+		t.Fatal("expect cache kept after close")
+	}
+}
@@ -9,9 +9,16 @@
 package test
 
 import (
+	"bytes"
+	"errors"
 	"github.com/xfali/goutils/v2/container/recycleMap"
 	"github.com/xfali/goutils/v2/pattern"
+	"io"
+	"math"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -167,3 +174,1138 @@ func test(dm recycleMap.RecycleMap[string, string], t *testing.T) {
 		t.Fatal("expect 3 but get ", size)
 	}
 }
+
+func TestRecycleMapSnapshot(t *testing.T) {
+	for name, codec := range map[string]recycleMap.Codec{"gob": recycleMap.GobCodec, "json": recycleMap.JSONCodec} {
+		t.Run(name, func(t *testing.T) {
+			dm := recycleMap.New(recycleMap.OptSetCodec[string, int](codec))
+			defer dm.Close()
+			dm.Set("a", 1, -1)
+			dm.Set("b", 2, time.Second)
+			dm.Set("c", 3, 10*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+
+			buf := &bytes.Buffer{}
+			if err := dm.Snapshot(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := recycleMap.New(recycleMap.OptSetCodec[string, int](codec))
+			defer restored.Close()
+			restored.Set("a", 100, -1)
+			if err := restored.Restore(buf); err != nil {
+				t.Fatal(err)
+			}
+			if restored.Size() != 2 {
+				t.Fatal("expect 2 but get ", restored.Size())
+			}
+			if v := restored.Get("a"); v != 1 {
+				t.Fatal("expect 1 but get ", v)
+			}
+			if restored.TTL("a") != -1 {
+				t.Fatal("a must never expire")
+			}
+			if ttl := restored.TTL("b"); ttl <= 0 || ttl > time.Second {
+				t.Fatal("ttl of b must be preserved but get ", ttl)
+			}
+			if restored.TTL("c") != -2 {
+				t.Fatal("expired key must not be restored")
+			}
+		})
+	}
+}
+
+func TestRecycleMapAppendLog(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dm := recycleMap.New(recycleMap.OptAppendLog[string, string](f, true))
+	dm.Set("a", "1", -1)
+	dm.Set("b", "2", -1)
+	dm.Set("c", "3", -1)
+	dm.Set("a", "11", -1)
+	dm.Delete("b")
+	dm.SetExpire("c", 10*time.Millisecond)
+	dm.Close()
+
+	// 第二次启动继续追加到同一个文件
+	dm = recycleMap.New(recycleMap.OptAppendLog[string, string](f, false))
+	dm.Set("d", "4", time.Hour)
+	dm.Close()
+	// 模拟崩溃时写入了不完整的记录
+	f.Write([]byte{0, 0, 1, 0, 1, 2})
+
+	time.Sleep(20 * time.Millisecond)
+	r, err := os.Open(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	notified := 0
+	restored := recycleMap.New(recycleMap.OptSetDeleteNotifier(func(key string, value string) {
+		notified++
+	}))
+	defer restored.Close()
+	events := 0
+	restored.Subscribe("", recycleMap.EventAll, func(e recycleMap.Event[string, string]) {
+		events++
+	})
+	err = restored.Restore(r)
+	var recErr *recycleMap.RecordError
+	if !errors.As(err, &recErr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("expect torn record error but get ", err)
+	}
+	info, _ := f.Stat()
+	if recErr.Offset != info.Size()-6 {
+		t.Fatal("expect offset ", info.Size()-6, " but get ", recErr.Offset)
+	}
+	// 重放历史记录不产生删除通知和事件
+	if notified != 0 || events != 0 {
+		t.Fatal("expect no notification on restore but get ", notified, events)
+	}
+	if restored.Size() != 2 {
+		t.Fatal("expect 2 but get ", restored.Size())
+	}
+	if v := restored.Get("a"); v != "11" {
+		t.Fatal("expect 11 but get ", v)
+	}
+	if v := restored.Get("d"); v != "4" || restored.TTL("d") <= 0 {
+		t.Fatal("expect 4 with ttl but get ", v)
+	}
+
+	t.Run("truncate torn tail", func(t *testing.T) {
+		dm := recycleMap.New[string, string]()
+		if err := recycleMap.RestoreAppendLog(dm, f); err != nil {
+			t.Fatal(err)
+		}
+		dm.Close()
+		if info, _ := f.Stat(); info.Size() != recErr.Offset {
+			t.Fatal("expect truncate to ", recErr.Offset, " but get ", info.Size())
+		}
+		// 截断后继续追加
+		dm = recycleMap.New(recycleMap.OptAppendLog[string, string](f, false))
+		dm.Set("e", "5", -1)
+		dm.Close()
+
+		restored := recycleMap.New[string, string]()
+		defer restored.Close()
+		if err := recycleMap.RestoreAppendLog(restored, f); err != nil {
+			t.Fatal(err)
+		}
+		if restored.Size() != 3 || restored.Get("e") != "5" {
+			t.Fatal("expect 3 keys with e=5 but get ", restored.Size(), restored.Get("e"))
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		dm := recycleMap.New(recycleMap.OptAppendLog[string, string](buf, false))
+		dm.Set("a", "1", -1)
+		dm.Set("b", "2", -1)
+		dm.Close()
+		data := buf.Bytes()
+		// 破坏最后一条记录的数据
+		data[len(data)-1] ^= 0xff
+
+		restored := recycleMap.New[string, string]()
+		defer restored.Close()
+		err := restored.Restore(bytes.NewReader(data))
+		var recErr *recycleMap.RecordError
+		if !errors.As(err, &recErr) || !errors.Is(err, recycleMap.ErrCorruptRecord) || recErr.Offset == 0 {
+			t.Fatal("expect corrupt record error but get ", err)
+		}
+		if restored.Size() != 1 || restored.Get("a") != "1" {
+			t.Fatal("expect a=1 only")
+		}
+	})
+}
+
+func TestRecycleMapAppendLogError(t *testing.T) {
+	dm := recycleMap.New(recycleMap.OptAppendLog[string, string](errWriter{}, false))
+	defer dm.Close()
+	if err := dm.Set("a", "1", -1); err == nil {
+		t.Fatal("expect append log error")
+	}
+	if err := dm.Set("b", "2", -1); err == nil {
+		t.Fatal("expect append log error")
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestRecycleMapTx(t *testing.T) {
+	dm := recycleMap.New[string, string]()
+	defer dm.Close()
+
+	t.Run("exec", func(t *testing.T) {
+		dm.Set("a", "1", -1)
+		tx := dm.Multi()
+		tx.Set("b", "2", -1).Delete("a").SetExpire("b", time.Hour)
+		if dm.Get("b") != "" || dm.Get("a") != "1" {
+			t.Fatal("queued ops must not apply before Exec")
+		}
+		if err := tx.Exec(); err != nil {
+			t.Fatal(err)
+		}
+		if dm.Get("a") != "" || dm.Get("b") != "2" || dm.TTL("b") <= 0 {
+			t.Fatal("expect tx applied")
+		}
+		if err := tx.Exec(); err != recycleMap.ErrTxDone {
+			t.Fatal("expect ErrTxDone but get ", err)
+		}
+	})
+
+	t.Run("watch abort", func(t *testing.T) {
+		tx := dm.Multi()
+		if err := tx.Watch("c"); err != nil {
+			t.Fatal(err)
+		}
+		dm.Set("c", "other", -1)
+		tx.Set("c", "mine", -1)
+		if err := tx.Exec(); err != recycleMap.ErrTxAborted {
+			t.Fatal("expect ErrTxAborted but get ", err)
+		}
+		if v := dm.Get("c"); v != "other" {
+			t.Fatal("expect other but get ", v)
+		}
+	})
+
+	t.Run("watch expire", func(t *testing.T) {
+		dm.Set("e", "1", 10*time.Millisecond)
+		tx := dm.Multi()
+		tx.Watch("e")
+		time.Sleep(20 * time.Millisecond)
+		dm.Get("e")
+		tx.Set("e", "2", -1)
+		if err := tx.Exec(); err != recycleMap.ErrTxAborted {
+			t.Fatal("expect ErrTxAborted but get ", err)
+		}
+	})
+
+	t.Run("watch lazy expire", func(t *testing.T) {
+		// 不访问也不清理，过期的key没有被删除
+		dm := recycleMap.New(recycleMap.OptManualPurge[string, string]())
+		defer dm.Close()
+		dm.Set("e", "1", 10*time.Millisecond)
+		tx := dm.Multi()
+		tx.Watch("e", "none")
+		time.Sleep(20 * time.Millisecond)
+		tx.Set("e", "2", -1)
+		if err := tx.Exec(); err != recycleMap.ErrTxAborted {
+			t.Fatal("expect ErrTxAborted but get ", err)
+		}
+
+		// 监视时不存在的key仍然不存在
+		tx = dm.Multi()
+		tx.Watch("none")
+		tx.Set("none", "1", -1)
+		if err := tx.Exec(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("watch unchanged", func(t *testing.T) {
+		dm.Set("d", "1", -1)
+		tx := dm.Multi()
+		tx.Watch("d")
+		dm.Set("other", "x", -1)
+		tx.Set("d", dm.Get("d")+"1", -1)
+		if err := tx.Exec(); err != nil {
+			t.Fatal(err)
+		}
+		if v := dm.Get("d"); v != "11" {
+			t.Fatal("expect 11 but get ", v)
+		}
+	})
+
+	t.Run("discard", func(t *testing.T) {
+		tx := dm.Multi()
+		tx.Watch("f")
+		tx.Set("f", "1", -1)
+		if err := tx.Discard(); err != nil {
+			t.Fatal(err)
+		}
+		if dm.Get("f") != "" {
+			t.Fatal("discarded op applied")
+		}
+		if err := tx.Watch("f"); err != recycleMap.ErrTxDone {
+			t.Fatal("expect ErrTxDone but get ", err)
+		}
+	})
+
+	t.Run("concurrent incr", func(t *testing.T) {
+		dm.Set("n", "0", -1)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; {
+					tx := dm.Multi()
+					tx.Watch("n")
+					n, _ := strconv.Atoi(dm.Get("n"))
+					tx.Set("n", strconv.Itoa(n+1), -1)
+					if tx.Exec() == nil {
+						j++
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if v := dm.Get("n"); v != "400" {
+			t.Fatal("expect 400 but get ", v)
+		}
+	})
+}
+
+func TestRecycleMapTxAppendLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dm := recycleMap.New(recycleMap.OptAppendLog[string, string](buf, false))
+	defer dm.Close()
+	tx := dm.Multi()
+	tx.Set("a", "1", -1).Set("b", "2", -1).Delete("a")
+	if err := tx.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	restored := recycleMap.New[string, string]()
+	defer restored.Close()
+	if err := restored.Restore(buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Size() != 1 || restored.Get("b") != "2" {
+		t.Fatal("expect b=2 only")
+	}
+}
+
+func TestRecycleMapSubscribe(t *testing.T) {
+	dm := recycleMap.New(recycleMap.OptManualPurge[string, string]())
+	defer dm.Close()
+
+	var events []recycleMap.Event[string, string]
+	cancel := dm.Subscribe("", recycleMap.EventAll, func(e recycleMap.Event[string, string]) {
+		events = append(events, e)
+	})
+	var aEvents []recycleMap.EventType
+	dm.Subscribe("a", recycleMap.EventDelete|recycleMap.EventExpire, func(e recycleMap.Event[string, string]) {
+		aEvents = append(aEvents, e.Type)
+	})
+	ch := make(chan recycleMap.Event[string, string], 10)
+	dm.SubscribeChan("", recycleMap.EventExpire, ch)
+
+	dm.Set("a", "1", -1)
+	dm.Set("a", "2", 10*time.Millisecond)
+	dm.Set("b", "1", 10*time.Millisecond)
+	dm.Set("c", "1", -1)
+	dm.Delete("c")
+	time.Sleep(20 * time.Millisecond)
+	dm.Get("a")
+	dm.Purge()
+
+	expect := []recycleMap.Event[string, string]{
+		{Type: recycleMap.EventSet, Key: "a", Value: "1"},
+		{Type: recycleMap.EventUpdate, Key: "a", Value: "2"},
+		{Type: recycleMap.EventSet, Key: "b", Value: "1"},
+		{Type: recycleMap.EventSet, Key: "c", Value: "1"},
+		{Type: recycleMap.EventDelete, Key: "c", Value: "1"},
+		{Type: recycleMap.EventExpire, Key: "a", Value: "2"},
+		{Type: recycleMap.EventExpire, Key: "b", Value: "1"},
+	}
+	if len(events) != len(expect) {
+		t.Fatal("expect ", expect, " but get ", events)
+	}
+	for i := range expect {
+		if events[i] != expect[i] {
+			t.Fatal("expect ", expect[i], " but get ", events[i])
+		}
+	}
+	if len(aEvents) != 1 || aEvents[0] != recycleMap.EventExpire {
+		t.Fatal("expect only expire event for a but get ", aEvents)
+	}
+	// 访问时被动删除与Purge回收都是过期事件
+	for _, key := range []string{"a", "b"} {
+		select {
+		case e := <-ch:
+			if e.Type != recycleMap.EventExpire || e.Key != key {
+				t.Fatal("expect expire ", key, " but get ", e)
+			}
+		default:
+			t.Fatal("expect expire event from channel")
+		}
+	}
+
+	cancel()
+	n := len(events)
+	dm.Set("d", "1", -1)
+	if len(events) != n {
+		t.Fatal("expect no events after cancel")
+	}
+}
+
+func TestShardedRecycleMap(t *testing.T) {
+	t.Run("test", func(t *testing.T) {
+		dm := recycleMap.NewSharded[string, string](4)
+		defer dm.Close()
+		test(dm, t)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		dm := recycleMap.NewSharded(8, recycleMap.OptManualPurge[int, int]())
+		defer dm.Close()
+		evicted := 0
+		dm.Subscribe(0, recycleMap.EventExpire, func(e recycleMap.Event[int, int]) {
+			evicted++
+		})
+		for i := 0; i < 100; i++ {
+			if i%2 == 0 {
+				dm.Set(i, i, time.Millisecond)
+			} else {
+				dm.Set(i, i, -1)
+			}
+		}
+		if len(dm.Keys(0)) != 100 {
+			t.Fatal("expect 100 keys")
+		}
+		time.Sleep(5 * time.Millisecond)
+		dm.Purge()
+		if evicted != 50 || dm.Size() != 50 {
+			t.Fatal("expect 50 evicted and 50 left but get ", evicted, dm.Size())
+		}
+		if dm.Delete(1, 3, 4) != 2 {
+			t.Fatal("expect delete 2")
+		}
+	})
+
+	t.Run("pointer keys", func(t *testing.T) {
+		type node struct{ v int }
+		dm := recycleMap.NewSharded[*node, int](16)
+		defer dm.Close()
+		nodes := make([]*node, 100)
+		for i := range nodes {
+			nodes[i] = &node{}
+			dm.Set(nodes[i], i, -1)
+		}
+		for i, n := range nodes {
+			n.v = i + 1
+			if v, ok := dm.Lookup(n); !ok || v != i {
+				t.Fatal("expect ", i, " but get ", v, ok)
+			}
+		}
+	})
+
+	t.Run("float keys", func(t *testing.T) {
+		dm := recycleMap.NewSharded[float64, int](16)
+		defer dm.Close()
+		dm.Set(0.0, 1, -1)
+		if v, ok := dm.Lookup(math.Copysign(0, -1)); !ok || v != 1 {
+			t.Fatal("expect -0.0 equals 0.0 but get ", v, ok)
+		}
+		dm.Set(math.Copysign(0, -1), 2, -1)
+		if dm.Size() != 1 {
+			t.Fatal("expect 1 but get ", dm.Size())
+		}
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		dm := recycleMap.NewSharded[string, string](4)
+		defer dm.Close()
+		for i := 0; i < 20; i++ {
+			dm.Set(strconv.Itoa(i), strconv.Itoa(i), time.Hour)
+		}
+		buf := &bytes.Buffer{}
+		if err := dm.Snapshot(buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := recycleMap.NewSharded[string, string](3)
+		defer restored.Close()
+		if err := restored.Restore(buf); err != nil {
+			t.Fatal(err)
+		}
+		if restored.Size() != 20 || restored.Get("7") != "7" || restored.TTL("7") <= 0 {
+			t.Fatal("restore failed")
+		}
+	})
+
+	t.Run("tx", func(t *testing.T) {
+		dm := recycleMap.NewSharded[string, string](4)
+		defer dm.Close()
+		dm.Set("n", "0", -1)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; {
+					tx := dm.Multi()
+					tx.Watch("n")
+					n, _ := strconv.Atoi(dm.Get("n"))
+					tx.Set("n", strconv.Itoa(n+1), -1).Set(strconv.Itoa(i), strconv.Itoa(j), -1)
+					if tx.Exec() == nil {
+						j++
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if v := dm.Get("n"); v != "400" {
+			t.Fatal("expect 400 but get ", v)
+		}
+		if dm.Size() != 9 {
+			t.Fatal("expect 9 but get ", dm.Size())
+		}
+	})
+
+	t.Run("watch across shards", func(t *testing.T) {
+		dm := recycleMap.NewSharded[int, int](16)
+		defer dm.Close()
+		keys := make([]int, 64)
+		for i := range keys {
+			keys[i] = i
+		}
+		tx := dm.Multi()
+		tx.Watch(keys...)
+		tx.Set(-1, -1, -1)
+		// 不同分片的写入并发标记同一个事务
+		var wg sync.WaitGroup
+		for _, k := range keys {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				dm.Set(k, k, -1)
+			}(k)
+		}
+		wg.Wait()
+		if err := tx.Exec(); err != recycleMap.ErrTxAborted {
+			t.Fatal("expect ErrTxAborted but get ", err)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		dm := recycleMap.NewSharded[int, int](0, recycleMap.OptSetPurgeInterval[int, int](time.Millisecond))
+		defer dm.Close()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					key := i*1000 + j
+					dm.Set(key, j, time.Duration(j%3)*time.Millisecond)
+					dm.Get(key)
+					dm.TTL(key)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func BenchmarkRecycleMapParallel(b *testing.B) {
+	b.Run("default", func(b *testing.B) {
+		benchmarkRecycleMapParallel(b, recycleMap.New[int, int]())
+	})
+	b.Run("sharded", func(b *testing.B) {
+		benchmarkRecycleMapParallel(b, recycleMap.NewSharded[int, int](0))
+	})
+}
+
+func benchmarkRecycleMapParallel(b *testing.B, dm recycleMap.RecycleMap[int, int]) {
+	defer dm.Close()
+	for i := 0; i < 10000; i++ {
+		dm.Set(i, i, time.Hour)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%4 == 0 {
+				dm.Set(i%10000, i, time.Hour)
+			} else {
+				dm.Get(i % 10000)
+			}
+			i++
+		}
+	})
+}
+
+func TestRecycleMapPurgeExpiryIndex(t *testing.T) {
+	var evicted []int
+	dm := recycleMap.New(recycleMap.OptManualPurge[int, int](),
+		recycleMap.OptSetPurgeNumberPerTime[int, int](3))
+	defer dm.Close()
+	dm.Subscribe(0, recycleMap.EventExpire, func(e recycleMap.Event[int, int]) {
+		evicted = append(evicted, e.Key)
+	})
+
+	// 过期时间间隔足够大，保证下面的修改在key 1过期之前完成，过期顺序与执行速度无关
+	const step = 50 * time.Millisecond
+	start := time.Now()
+	for i := 1; i <= 10; i++ {
+		dm.Set(i, i, time.Duration(i)*step)
+	}
+	// 永不过期
+	dm.SetExpire(2, -1)
+	// 延后过期
+	dm.SetExpire(3, time.Hour)
+	// 覆盖为永不过期
+	dm.Set(4, 4, -1)
+	// 提前过期
+	dm.SetExpire(10, 0)
+	dm.Delete(5)
+	if time.Since(start) >= step {
+		t.Skip("setup took longer than ", step)
+	}
+
+	time.Sleep(10 * step)
+	dm.Purge()
+	if len(evicted) != 3 {
+		t.Fatal("expect purge 3 per time but get ", evicted)
+	}
+	if evicted[0] != 10 || evicted[1] != 1 || evicted[2] != 6 {
+		t.Fatal("expect purge by expire time but get ", evicted)
+	}
+	dm.Purge()
+	dm.Purge()
+	if len(evicted) != 6 {
+		t.Fatal("expect 6 evicted but get ", evicted)
+	}
+	if dm.Size() != 3 {
+		t.Fatal("expect 3 but get ", dm.Size())
+	}
+	for _, k := range []int{2, 3, 4} {
+		if dm.Get(k) != k {
+			t.Fatal("expect ", k, " exists")
+		}
+	}
+}
+
+func BenchmarkRecycleMapPurge(b *testing.B) {
+	dm := recycleMap.New(recycleMap.OptManualPurge[int, int]())
+	defer dm.Close()
+	for i := 0; i < 2000000; i++ {
+		dm.Set(i, i, -1)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dm.Set(-1, i, 0)
+		dm.Purge()
+	}
+}
+
+func TestRecycleMapValueOps(t *testing.T) {
+	maps := map[string]func() recycleMap.RecycleMap[string, int]{
+		"default": func() recycleMap.RecycleMap[string, int] { return recycleMap.New[string, int]() },
+		"sharded": func() recycleMap.RecycleMap[string, int] { return recycleMap.NewSharded[string, int](4) },
+	}
+	for name, creator := range maps {
+		t.Run(name, func(t *testing.T) {
+			dm := creator()
+			defer dm.Close()
+
+			if ok, _ := dm.SetNX("a", 1, time.Hour); !ok {
+				t.Fatal("expect SetNX success")
+			}
+			if ok, _ := dm.SetNX("a", 2, -1); ok {
+				t.Fatal("expect SetNX fail")
+			}
+			if dm.Get("a") != 1 {
+				t.Fatal("expect 1")
+			}
+
+			old, exists, _ := dm.GetSet("a", 3, recycleMap.KeepTTL)
+			if !exists || old != 1 || dm.Get("a") != 3 || dm.TTL("a") <= 0 {
+				t.Fatal("expect GetSet return 1 and keep ttl")
+			}
+			old, exists, _ = dm.GetSet("b", 1, -1)
+			if exists || old != 0 {
+				t.Fatal("expect b not exists")
+			}
+
+			if ok, _ := dm.CompareAndSwap("a", 1, 5, recycleMap.KeepTTL); ok {
+				t.Fatal("expect CAS fail")
+			}
+			if ok, _ := dm.CompareAndSwap("a", 3, 5, -1); !ok {
+				t.Fatal("expect CAS success")
+			}
+			if dm.Get("a") != 5 || dm.TTL("a") != -1 {
+				t.Fatal("expect 5 without ttl")
+			}
+
+			v, ok := dm.GetAndDelete("a")
+			if !ok || v != 5 || dm.TTL("a") != -2 {
+				t.Fatal("expect GetAndDelete return 5")
+			}
+			if _, ok := dm.GetAndDelete("a"); ok {
+				t.Fatal("expect a not exists")
+			}
+
+			dm.Set("c", 1, 10*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+			if ok, _ := dm.SetNX("c", 2, -1); !ok {
+				t.Fatal("expect SetNX success on expired key")
+			}
+
+			v, _ = dm.Update("d", func(old int, exists bool) int {
+				if exists {
+					t.Fatal("expect d not exists")
+				}
+				return 10
+			}, time.Hour)
+			if v != 10 {
+				t.Fatal("expect 10")
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						recycleMap.Incr(dm, "n", 2)
+						recycleMap.Decr(dm, "n", 1)
+					}
+				}()
+			}
+			wg.Wait()
+			if dm.Get("n") != 800 || dm.TTL("n") != -1 {
+				t.Fatal("expect 800 but get ", dm.Get("n"))
+			}
+			dm.SetExpire("n", time.Hour)
+			recycleMap.Incr(dm, "n", 1)
+			if dm.TTL("n") <= 0 {
+				t.Fatal("expect Incr keep ttl")
+			}
+
+			// KeepTTL不修改过期时间
+			dm.Set("p", 1, -1)
+			if !dm.SetExpire("p", recycleMap.KeepTTL) || dm.TTL("p") != -1 {
+				t.Fatal("expect p still persistent")
+			}
+			if !dm.SetExpire("n", recycleMap.KeepTTL) || dm.TTL("n") <= 0 {
+				t.Fatal("expect n keep ttl")
+			}
+			if dm.SetExpire("none", recycleMap.KeepTTL) {
+				t.Fatal("expect none not exists")
+			}
+		})
+	}
+}
+
+func TestRecycleMapAppend(t *testing.T) {
+	sm := recycleMap.New[string, string]()
+	defer sm.Close()
+	if n, err := recycleMap.Append(sm, "a", "hello"); err != nil || n != 5 {
+		t.Fatal("expect 5 but get ", n, err)
+	}
+	sm.SetExpire("a", time.Hour)
+	if n, _ := recycleMap.Append(sm, "a", " world"); n != 11 || sm.Get("a") != "hello world" {
+		t.Fatal("expect hello world but get ", sm.Get("a"))
+	}
+	if sm.TTL("a") <= 0 {
+		t.Fatal("expect Append keep ttl")
+	}
+
+	bm := recycleMap.NewSharded[string, []byte](4)
+	defer bm.Close()
+	old := make([]byte, 1, 10)
+	old[0] = 'a'
+	bm.Set("b", old, -1)
+	if n, _ := recycleMap.Append(bm, "b", []byte("bc")); n != 3 || string(bm.Get("b")) != "abc" {
+		t.Fatal("expect abc but get ", string(bm.Get("b")))
+	}
+	if string(old[:cap(old)][:3]) == "abc" {
+		t.Fatal("expect old value not modified")
+	}
+}
+
+func TestRecycleMapCompareAndSwapEqual(t *testing.T) {
+	dm := recycleMap.New[string, []byte]()
+	defer dm.Close()
+	dm.Set("a", []byte("1"), -1)
+	if ok, _ := dm.CompareAndSwap("a", []byte("1"), []byte("2"), -1); !ok {
+		t.Fatal("expect CAS success with DeepEqual fallback")
+	}
+
+	fm := recycleMap.New(recycleMap.OptSetEqualFunc[string, float64](func(a, b float64) bool {
+		return a-b < 0.01 && b-a < 0.01
+	}))
+	defer fm.Close()
+	fm.Set("a", 1.001, -1)
+	if ok, _ := fm.CompareAndSwap("a", 1, 2, -1); !ok {
+		t.Fatal("expect CAS success with equal func")
+	}
+}
+
+func TestRecycleMapLookup(t *testing.T) {
+	maps := map[string]func() recycleMap.RecycleMap[string, int]{
+		"default": func() recycleMap.RecycleMap[string, int] { return recycleMap.New[string, int]() },
+		"sharded": func() recycleMap.RecycleMap[string, int] { return recycleMap.NewSharded[string, int](4) },
+	}
+	for name, creator := range maps {
+		t.Run(name, func(t *testing.T) {
+			dm := creator()
+			defer dm.Close()
+
+			dm.Set("zero", 0, -1)
+			if v, ok := dm.Lookup("zero"); !ok || v != 0 {
+				t.Fatal("expect zero value exists")
+			}
+			if _, ok := dm.Lookup("none"); ok {
+				t.Fatal("expect none not exists")
+			}
+			dm.Set("exp", 1, 10*time.Millisecond)
+			if v, ttl, ok := dm.GetWithTTL("exp"); !ok || v != 1 || ttl <= 0 || ttl > 10*time.Millisecond {
+				t.Fatal("expect exp with ttl but get ", v, ttl, ok)
+			}
+			if _, ttl, ok := dm.GetWithTTL("zero"); !ok || ttl != -1 {
+				t.Fatal("expect zero without ttl")
+			}
+			time.Sleep(20 * time.Millisecond)
+			if _, ttl, ok := dm.GetWithTTL("exp"); ok || ttl != -2 {
+				t.Fatal("expect exp expired")
+			}
+
+			err := dm.MSet(
+				recycleMap.Entry[string, int]{Key: "a", Value: 1, ExpireIn: -1},
+				recycleMap.Entry[string, int]{Key: "b", Value: 2, ExpireIn: time.Hour},
+				recycleMap.Entry[string, int]{Key: "c", Value: 3, ExpireIn: 0},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dm.TTL("a") != -1 || dm.TTL("b") <= 0 {
+				t.Fatal("expect per-key ttl")
+			}
+			values := dm.MGet("a", "b", "c", "none")
+			if len(values) != 2 || values["a"] != 1 || values["b"] != 2 {
+				t.Fatal("expect a and b but get ", values)
+			}
+
+			got := map[string]int{}
+			dm.Range(func(key string, value int, ttl time.Duration) bool {
+				got[key] = value
+				// 快照遍历，可以在回调中修改map
+				dm.Delete(key)
+				return true
+			})
+			if len(got) != 3 || got["zero"] != 0 || got["a"] != 1 || got["b"] != 2 {
+				t.Fatal("expect zero, a, b but get ", got)
+			}
+			if dm.Size() != 0 {
+				t.Fatal("expect empty")
+			}
+
+			for i := 0; i < 10; i++ {
+				dm.Set(strconv.Itoa(i), i, -1)
+			}
+			n := 0
+			dm.Range(func(key string, value int, ttl time.Duration) bool {
+				n++
+				return n < 3
+			})
+			if n != 3 {
+				t.Fatal("expect stop after 3 but get ", n)
+			}
+		})
+	}
+}
+
+func TestRecycleMapRace(t *testing.T) {
+	maps := map[string]recycleMap.RecycleMap[int, int]{
+		"default": recycleMap.New(recycleMap.OptSetPurgeInterval[int, int](time.Millisecond)),
+		"sharded": recycleMap.NewSharded(4, recycleMap.OptSetPurgeInterval[int, int](time.Millisecond)),
+	}
+	for name, dm := range maps {
+		t.Run(name, func(t *testing.T) {
+			defer dm.Close()
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 500; j++ {
+						key := j % 50
+						switch (i + j) % 8 {
+						case 0:
+							dm.Set(key, j, time.Duration(j%3)*time.Millisecond)
+						case 1:
+							dm.Lookup(key)
+						case 2:
+							dm.GetWithTTL(key)
+						case 3:
+							dm.MGet(key, key+1, key+2)
+						case 4:
+							dm.MSet(recycleMap.Entry[int, int]{Key: key, Value: j, ExpireIn: time.Millisecond},
+								recycleMap.Entry[int, int]{Key: key + 1, Value: j, ExpireIn: -1})
+						case 5:
+							dm.Range(func(key int, value int, ttl time.Duration) bool {
+								return true
+							})
+						case 6:
+							dm.Size()
+						case 7:
+							dm.Delete(key)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		str     string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"a*", "bac", false},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a**c", "abbbc", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[b-a]llo", "hallo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{"user:*:name", "user:1000:name", true},
+		{"user:*:name", "user:1000:age", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, c := range cases {
+		if recycleMap.GlobMatch(c.pattern, c.str) != c.match {
+			t.Fatal("pattern ", c.pattern, " str ", c.str, " expect ", c.match)
+		}
+	}
+}
+
+func TestRecycleMapScan(t *testing.T) {
+	maps := map[string]func() recycleMap.RecycleMap[string, int]{
+		"default": func() recycleMap.RecycleMap[string, int] {
+			return recycleMap.New(recycleMap.OptSetMatcher[string, int](recycleMap.GlobMatcher[string](nil)))
+		},
+		"sharded": func() recycleMap.RecycleMap[string, int] {
+			return recycleMap.NewSharded(4, recycleMap.OptSetMatcher[string, int](recycleMap.GlobMatcher[string](nil)))
+		},
+	}
+	for name, creator := range maps {
+		t.Run(name, func(t *testing.T) {
+			dm := creator()
+			defer dm.Close()
+			for i := 0; i < 100; i++ {
+				dm.Set("user:"+strconv.Itoa(i), i, -1)
+				dm.Set("item:"+strconv.Itoa(i), i, -1)
+			}
+			if keys := dm.Keys("user:1?"); len(keys) != 10 {
+				t.Fatal("expect 10 but get ", keys)
+			}
+
+			seen := map[string]int{}
+			var cursor uint64
+			calls := 0
+			for {
+				next, keys := dm.Scan(cursor, "user:*", 7)
+				calls++
+				if len(keys) > 7 {
+					t.Fatal("expect at most 7 keys but get ", len(keys))
+				}
+				for _, k := range keys {
+					seen[k]++
+				}
+				// 遍历过程中删除并新增key
+				dm.Delete("item:" + strconv.Itoa(calls))
+				dm.Set("new:"+strconv.Itoa(calls), calls, -1)
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+			if len(seen) != 100 {
+				t.Fatal("expect all 100 user keys but get ", len(seen))
+			}
+			if calls < 200/7 {
+				t.Fatal("expect incremental scan but get calls ", calls)
+			}
+
+			// match为nil时返回所有key
+			all := map[string]bool{}
+			cursor = 0
+			for {
+				next, keys := dm.ScanFunc(cursor, nil, 7)
+				for _, k := range keys {
+					all[k] = true
+				}
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+			if int64(len(all)) != dm.Size() {
+				t.Fatal("expect ", dm.Size(), " keys but get ", len(all))
+			}
+		})
+	}
+}
+
+func TestRecycleMapGetOrLoad(t *testing.T) {
+	for name, newMap := range map[string]func(opts ...recycleMap.Opt[int, string]) recycleMap.RecycleMap[int, string]{
+		"default": recycleMap.New[int, string],
+		"sharded": func(opts ...recycleMap.Opt[int, string]) recycleMap.RecycleMap[int, string] {
+			return recycleMap.NewSharded(4, opts...)
+		},
+	} {
+		t.Run(name+" singleflight", func(t *testing.T) {
+			dm := newMap(recycleMap.OptLoadTTL[int, string](time.Hour))
+			defer dm.Close()
+			var calls int32
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					v, err := dm.GetOrLoad(1, func(key int) (string, error) {
+						atomic.AddInt32(&calls, 1)
+						<-release
+						return strconv.Itoa(key), nil
+					})
+					if err != nil || v != "1" {
+						t.Error("load failed: ", v, err)
+					}
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			if n := atomic.LoadInt32(&calls); n != 1 {
+				t.Fatal("expect 1 load but get ", n)
+			}
+			if v, ok := dm.Lookup(1); !ok || v != "1" || dm.TTL(1) <= 0 {
+				t.Fatal("loaded value must be set with ttl")
+			}
+		})
+
+		t.Run(name+" negative", func(t *testing.T) {
+			dm := newMap(recycleMap.OptNegativeTTL[int, string](50 * time.Millisecond))
+			defer dm.Close()
+			calls := 0
+			loadErr := errors.New("load error")
+			loader := func(key int) (string, error) {
+				calls++
+				if calls == 1 {
+					return "", loadErr
+				}
+				return "ok", nil
+			}
+			if _, err := dm.GetOrLoad(1, loader); err != loadErr {
+				t.Fatal("expect load error but get ", err)
+			}
+			if _, err := dm.GetOrLoad(1, loader); err != loadErr || calls != 1 {
+				t.Fatal("error must be cached, calls: ", calls)
+			}
+			time.Sleep(60 * time.Millisecond)
+			if v, err := dm.GetOrLoad(1, loader); err != nil || v != "ok" || calls != 2 || dm.TTL(1) != -1 {
+				t.Fatal("must reload after negative ttl: ", v, err, calls)
+			}
+
+			dm.GetOrLoad(2, func(key int) (string, error) {
+				return "", loadErr
+			})
+			dm.Set(2, "set", -1)
+			dm.Delete(2)
+			if v, err := dm.GetOrLoad(2, loader); err != nil || v != "ok" {
+				t.Fatal("set must clear cached error: ", v, err)
+			}
+		})
+
+		t.Run(name+" refresh ahead", func(t *testing.T) {
+			dm := newMap(recycleMap.OptLoadTTL[int, string](100*time.Millisecond),
+				recycleMap.OptRefreshAhead[int, string](60*time.Millisecond))
+			defer dm.Close()
+			var version int32
+			loader := func(key int) (string, error) {
+				return strconv.Itoa(int(atomic.AddInt32(&version, 1))), nil
+			}
+			if v, _ := dm.GetOrLoad(1, loader); v != "1" {
+				t.Fatal("expect 1 but get ", v)
+			}
+			if v, _ := dm.GetOrLoad(1, loader); v != "1" || atomic.LoadInt32(&version) != 1 {
+				t.Fatal("must not refresh before refresh ahead window")
+			}
+			time.Sleep(50 * time.Millisecond)
+			if v, _ := dm.GetOrLoad(1, loader); v != "1" {
+				t.Fatal("expect 1 but get ", v)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if v, ok := dm.Lookup(1); !ok || v != "2" {
+				t.Fatal("expect refreshed value 2 but get ", v)
+			}
+		})
+
+		t.Run(name+" stale", func(t *testing.T) {
+			dm := newMap()
+			defer dm.Close()
+			started := make(chan struct{})
+			release := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				v, err := dm.GetOrLoad(1, func(key int) (string, error) {
+					close(started)
+					<-release
+					return "stale", nil
+				})
+				if err != nil || v != "stale" {
+					t.Error("loader caller must get loaded value: ", v, err)
+				}
+			}()
+			<-started
+			dm.Set(1, "set", -1)
+			close(release)
+			<-done
+			if v := dm.Get(1); v != "set" {
+				t.Fatal("load must not overwrite set value: ", v)
+			}
+		})
+
+		t.Run(name+" panic", func(t *testing.T) {
+			dm := newMap()
+			defer dm.Close()
+			func() {
+				defer func() {
+					if r := recover(); r != "boom" {
+						t.Fatal("expect panic but get ", r)
+					}
+				}()
+				dm.GetOrLoad(1, func(key int) (string, error) {
+					panic("boom")
+				})
+			}()
+			if v, err := dm.GetOrLoad(1, func(key int) (string, error) {
+				return "ok", nil
+			}); err != nil || v != "ok" {
+				t.Fatal("load after panic failed: ", v, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/xfali/goutils/v2/container/recycleMap"
+	"github.com/xfali/goutils/v2/container/recycleMap/resp"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type respClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialResp(t *testing.T, s *resp.Server) *respClient {
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &respClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *respClient) send(args ...string) {
+	buf := fmt.Sprintf("*%d\r\n", len(args))
+	for _, a := range args {
+		buf += fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)
+	}
+	c.conn.Write([]byte(buf))
+}
+
+func (c *respClient) do(args ...string) interface{} {
+	c.send(args...)
+	return c.read()
+}
+
+// 简单字符串返回string，错误返回error，整数返回int64，bulk字符串返回[]byte，数组返回[]interface{}
+func (c *respClient) read() interface{} {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return errors.New(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		io.ReadFull(c.r, buf)
+		return buf[:n]
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		ret := make([]interface{}, n)
+		for i := range ret {
+			ret[i] = c.read()
+		}
+		return ret
+	}
+	return fmt.Errorf("unknown reply %s", line)
+}
+
+func expectReply(t *testing.T, got, expect interface{}) {
+	t.Helper()
+	if s, ok := expect.(string); ok {
+		if b, ok := got.([]byte); ok {
+			got = string(b)
+		}
+		expect = s
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %#v but get %#v", expect, got)
+	}
+}
+
+func expectError(t *testing.T, got interface{}) {
+	t.Helper()
+	if _, ok := got.(error); !ok {
+		t.Fatalf("expect error but get %#v", got)
+	}
+}
+
+func startResp(t *testing.T) (*resp.Server, recycleMap.RecycleMap[string, []byte]) {
+	m := recycleMap.New[string, []byte]()
+	s, err := resp.Start(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		m.Close()
+	})
+	return s, m
+}
+
+func TestRespServer(t *testing.T) {
+	s, m := startResp(t)
+	c := dialResp(t, s)
+
+	expectReply(t, c.do("PING"), "PONG")
+	expectReply(t, c.do("ECHO", "hello"), "hello")
+	expectReply(t, c.do("SELECT", "0"), "OK")
+	expectError(t, c.do("SELECT", "1"))
+	expectError(t, c.do("NOSUCHCMD"))
+	expectError(t, c.do("GET"))
+
+	t.Run("get set", func(t *testing.T) {
+		expectReply(t, c.do("GET", "a"), nil)
+		expectReply(t, c.do("SET", "a", "1"), "OK")
+		expectReply(t, c.do("GET", "a"), "1")
+		expectReply(t, c.do("SET", "a", "2", "NX"), nil)
+		expectReply(t, c.do("SET", "b", "2", "XX"), nil)
+		expectReply(t, c.do("EXISTS", "a", "b"), int64(1))
+		expectReply(t, c.do("SET", "a", "3", "XX", "GET"), "1")
+		expectReply(t, c.do("SET", "b", "1", "NX", "EX", "100"), "OK")
+		expectReply(t, c.do("TTL", "b"), int64(100))
+		expectReply(t, c.do("SET", "b", "2", "KEEPTTL"), "OK")
+		if ttl := c.do("PTTL", "b").(int64); ttl <= 99000 || ttl > 100000 {
+			t.Fatal("expect keep ttl but get ", ttl)
+		}
+		expectReply(t, c.do("SET", "b", "3"), "OK")
+		expectReply(t, c.do("TTL", "b"), int64(-1))
+		expectReply(t, c.do("TTL", "none"), int64(-2))
+		expectError(t, c.do("SET", "b", "1", "EX", "0"))
+		expectError(t, c.do("SET", "b", "1", "NX", "XX"))
+		expectReply(t, c.do("SET", "c", "1", "PX", "10"), "OK")
+		time.Sleep(20 * time.Millisecond)
+		expectReply(t, c.do("GET", "c"), nil)
+
+		expectReply(t, c.do("MSET", "k1", "v1", "k2", "v2"), "OK")
+		expectReply(t, c.do("MGET", "k1", "none", "k2"), []interface{}{[]byte("v1"), nil, []byte("v2")})
+		expectError(t, c.do("MSET", "k1", "v1", "k2"))
+		expectReply(t, c.do("SETNX", "k1", "x"), int64(0))
+		expectReply(t, c.do("GETSET", "k1", "x"), "v1")
+		expectReply(t, c.do("GETDEL", "k1"), "x")
+		expectReply(t, c.do("DEL", "k1", "k2", "a"), int64(2))
+		expectReply(t, c.do("TYPE", "b"), "string")
+		expectReply(t, c.do("TYPE", "a"), "none")
+
+		// 进程内直接访问map
+		if v, ok := m.Lookup("b"); !ok || string(v) != "3" {
+			t.Fatal("expect b=3 in map")
+		}
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		expectReply(t, c.do("EXPIRE", "none", "10"), int64(0))
+		expectReply(t, c.do("EXPIRE", "b", "10"), int64(1))
+		expectReply(t, c.do("TTL", "b"), int64(10))
+		expectReply(t, c.do("PERSIST", "b"), int64(1))
+		expectReply(t, c.do("PERSIST", "b"), int64(0))
+		expectReply(t, c.do("PEXPIRE", "b", "10"), int64(1))
+		time.Sleep(20 * time.Millisecond)
+		expectReply(t, c.do("EXISTS", "b"), int64(0))
+		c.do("SET", "b", "1")
+		expectReply(t, c.do("EXPIRE", "b", "0"), int64(1))
+		expectReply(t, c.do("EXISTS", "b"), int64(0))
+	})
+
+	t.Run("incr", func(t *testing.T) {
+		expectReply(t, c.do("INCR", "n"), int64(1))
+		expectReply(t, c.do("INCRBY", "n", "10"), int64(11))
+		expectReply(t, c.do("DECR", "n"), int64(10))
+		expectReply(t, c.do("DECRBY", "n", "20"), int64(-10))
+		expectReply(t, c.do("GET", "n"), "-10")
+		c.do("SET", "s", "abc")
+		expectError(t, c.do("INCR", "s"))
+		expectReply(t, c.do("GET", "s"), "abc")
+		c.do("SET", "max", strconv.FormatInt(1<<63-1, 10))
+		expectError(t, c.do("INCR", "max"))
+		c.do("EXPIRE", "n", "100")
+		c.do("INCR", "n")
+		expectReply(t, c.do("TTL", "n"), int64(100))
+	})
+
+	t.Run("keys scan", func(t *testing.T) {
+		expectReply(t, c.do("FLUSHDB"), "OK")
+		expectReply(t, c.do("DBSIZE"), int64(0))
+		for i := 0; i < 50; i++ {
+			c.do("SET", "user:"+strconv.Itoa(i), "1")
+			c.do("SET", "item:"+strconv.Itoa(i), "1")
+		}
+		expectReply(t, c.do("DBSIZE"), int64(100))
+		if keys := c.do("KEYS", "user:1?").([]interface{}); len(keys) != 10 {
+			t.Fatal("expect 10 keys but get ", len(keys))
+		}
+		expectReply(t, c.do("KEYS", "none*"), []interface{}{})
+		c.do("SET", "expired", "1", "PX", "1")
+		time.Sleep(5 * time.Millisecond)
+		// 已过期但未被清理的key
+		expectReply(t, c.do("KEYS", "expired"), []interface{}{})
+		expectReply(t, c.do("DBSIZE"), int64(100))
+
+		seen := map[string]bool{}
+		cursor := "0"
+		for {
+			reply := c.do("SCAN", cursor, "MATCH", "user:*", "COUNT", "7").([]interface{})
+			for _, k := range reply[1].([]interface{}) {
+				seen[string(k.([]byte))] = true
+			}
+			cursor = string(reply[0].([]byte))
+			if cursor == "0" {
+				break
+			}
+		}
+		if len(seen) != 50 {
+			t.Fatal("expect 50 keys but get ", len(seen))
+		}
+		expectError(t, c.do("SCAN", "x"))
+	})
+
+	t.Run("pipeline and inline", func(t *testing.T) {
+		c.send("SET", "p", "1")
+		c.send("INCR", "p")
+		c.send("GET", "p")
+		expectReply(t, c.read(), "OK")
+		expectReply(t, c.read(), int64(2))
+		expectReply(t, c.read(), "2")
+
+		c.conn.Write([]byte("PING\r\n"))
+		expectReply(t, c.read(), "PONG")
+	})
+}
+
+// KEYS、SCAN、FLUSHDB与RecycleMap配置的MatchFunc无关
+func TestRespServerCustomMatcher(t *testing.T) {
+	m := recycleMap.New(recycleMap.OptSetMatcher[string, []byte](func(pattern string) func(key string) bool {
+		return func(key string) bool {
+			return key == pattern
+		}
+	}))
+	defer m.Close()
+	s, err := resp.Start(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := dialResp(t, s)
+
+	for i := 0; i < 20; i++ {
+		c.do("SET", "user:"+strconv.Itoa(i), "1")
+	}
+	if keys := c.do("KEYS", "*").([]interface{}); len(keys) != 20 {
+		t.Fatal("expect 20 keys but get ", len(keys))
+	}
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		reply := c.do("SCAN", cursor, "MATCH", "user:1*", "COUNT", "3").([]interface{})
+		for _, k := range reply[1].([]interface{}) {
+			seen[string(k.([]byte))] = true
+		}
+		cursor = string(reply[0].([]byte))
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(seen) != 11 {
+		t.Fatal("expect 11 keys but get ", len(seen))
+	}
+	expectReply(t, c.do("FLUSHDB"), "OK")
+	expectReply(t, c.do("DBSIZE"), int64(0))
+}
+
+func TestRespServerMultiExec(t *testing.T) {
+	s, m := startResp(t)
+	c := dialResp(t, s)
+	other := dialResp(t, s)
+
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "1"), "QUEUED")
+	expectReply(t, c.do("INCR", "a"), "QUEUED")
+	expectReply(t, c.do("GET", "a"), "QUEUED")
+	expectReply(t, other.do("GET", "a"), nil)
+	expectReply(t, c.do("EXEC"), []interface{}{"OK", int64(2), []byte("2")})
+
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "3"), "QUEUED")
+	expectReply(t, c.do("DISCARD"), "OK")
+	expectReply(t, c.do("GET", "a"), "2")
+	expectError(t, c.do("EXEC"))
+
+	expectReply(t, c.do("MULTI"), "OK")
+	expectError(t, c.do("NOSUCHCMD"))
+	expectReply(t, c.do("SET", "a", "3"), "QUEUED")
+	expectError(t, c.do("EXEC"))
+	expectReply(t, c.do("GET", "a"), "2")
+
+	// WATCH的key被其他客户端修改
+	expectReply(t, c.do("WATCH", "a"), "OK")
+	expectReply(t, other.do("SET", "a", "10"), "OK")
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "3"), "QUEUED")
+	expectReply(t, c.do("EXEC"), nil)
+	expectReply(t, c.do("GET", "a"), "10")
+
+	// WATCH的key被进程内修改
+	expectReply(t, c.do("WATCH", "a"), "OK")
+	m.Set("a", []byte("20"), -1)
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "3"), "QUEUED")
+	expectReply(t, c.do("EXEC"), nil)
+
+	// 失败的INCR不修改WATCH的key
+	other.do("SET", "s", "abc")
+	expectReply(t, c.do("WATCH", "s"), "OK")
+	expectError(t, other.do("INCR", "s"))
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "s", "3"), "QUEUED")
+	expectReply(t, c.do("EXEC"), []interface{}{"OK"})
+
+	// WATCH的key未被修改
+	expectReply(t, c.do("WATCH", "a"), "OK")
+	expectReply(t, other.do("SET", "b", "1"), "OK")
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "3"), "QUEUED")
+	expectReply(t, c.do("EXEC"), []interface{}{"OK"})
+
+	expectReply(t, c.do("WATCH", "a"), "OK")
+	expectReply(t, c.do("UNWATCH"), "OK")
+	other.do("SET", "a", "4")
+	expectReply(t, c.do("MULTI"), "OK")
+	expectReply(t, c.do("SET", "a", "5"), "QUEUED")
+	expectReply(t, c.do("EXEC"), []interface{}{"OK"})
+
+	// 并发使用WATCH实现CAS
+	var wg sync.WaitGroup
+	c.do("SET", "n", "0")
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli := dialResp(t, s)
+			defer cli.conn.Close()
+			for j := 0; j < 20; {
+				cli.do("WATCH", "n")
+				n, _ := strconv.Atoi(string(cli.do("GET", "n").([]byte)))
+				cli.do("MULTI")
+				cli.do("SET", "n", strconv.Itoa(n+1))
+				if cli.do("EXEC") != nil {
+					j++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	expectReply(t, c.do("GET", "n"), "80")
+}
+
+func TestRespServerClose(t *testing.T) {
+	m := recycleMap.New[string, []byte]()
+	defer m.Close()
+	s, err := resp.Start(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialResp(t, s)
+	expectReply(t, c.do("PING"), "PONG")
+	expectReply(t, c.do("QUIT"), "OK")
+	if _, ok := c.read().(error); !ok {
+		t.Fatal("expect connection closed after QUIT")
+	}
+
+	c = dialResp(t, s)
+	expectReply(t, c.do("PING"), "PONG")
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.read().(error); !ok {
+		t.Fatal("expect connection closed after server Close")
+	}
+	if _, err := net.Dial("tcp", s.Addr().String()); err == nil {
+		t.Fatal("expect dial fail after server Close")
+	}
+}
+
+func TestRespServerProtocolError(t *testing.T) {
+	s, _ := startResp(t)
+	for _, raw := range []string{
+		"*-1\r\n",
+		"*0\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$999999999\r\n",
+		"*1\r\n$3\r\nGETX\r\n",
+		"PING " + strings.Repeat("a", 128*1024) + "\r\n",
+	} {
+		c := dialResp(t, s)
+		c.conn.Write([]byte(raw))
+		expectReply(t, c.read(), errors.New("ERR Protocol error"))
+		if _, ok := c.read().(error); !ok {
+			t.Fatal("expect connection closed after protocol error")
+		}
+		c.conn.Close()
+	}
+
+	// 声明长度后分多次发送
+	c := dialResp(t, s)
+	defer c.conn.Close()
+	c.conn.Write([]byte("*2\r\n$4\r\nECHO\r\n$5\r\nhe"))
+	time.Sleep(10 * time.Millisecond)
+	c.conn.Write([]byte("llo\r\n"))
+	expectReply(t, c.read(), "hello")
+}
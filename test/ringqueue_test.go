@@ -0,0 +1,193 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"context"
+	"github.com/xfali/goutils/v2/container"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRingQueue(t *testing.T) {
+	t.Run("capacity", func(t *testing.T) {
+		for capacity, expect := range map[int]int{0: 2, 1: 2, 3: 4, 8: 8, 100: 128} {
+			if c := container.NewRingQueue[int](capacity).Cap(); c != expect {
+				t.Fatal("capacity ", capacity, " expect ", expect, " but get ", c)
+			}
+		}
+	})
+
+	t.Run("try", func(t *testing.T) {
+		q := container.NewRingQueue[int](4)
+		if _, ok := q.TryPop(); ok {
+			t.Fatal("expect empty")
+		}
+		// 多轮以覆盖序号回绕
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 4; i++ {
+				if !q.TryPush(i) {
+					t.Fatal("expect push success")
+				}
+			}
+			if q.TryPush(4) {
+				t.Fatal("expect full")
+			}
+			if q.Len() != 4 {
+				t.Fatal("expect 4 but get ", q.Len())
+			}
+			for i := 0; i < 4; i++ {
+				if v, ok := q.TryPop(); !ok || v != i {
+					t.Fatal("expect ", i, " but get ", v)
+				}
+			}
+			if _, ok := q.TryPop(); ok {
+				t.Fatal("expect empty")
+			}
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		q := container.NewRingQueue[int](2)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := q.PopContext(ctx); err != context.DeadlineExceeded {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+		q.Push(1)
+		q.Push(2)
+		if err := q.PushContext(ctx, 3); err != context.DeadlineExceeded {
+			t.Fatal("expect DeadlineExceeded but get ", err)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		q := container.NewRingQueue[int](2)
+		q.Push(1)
+		q.Push(2)
+		errCh := make(chan error)
+		go func() {
+			errCh <- q.PushContext(context.Background(), 3)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		q.Close()
+		if err := <-errCh; err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+		if q.TryPush(3) {
+			t.Fatal("expect push fail after close")
+		}
+		if v := q.Pop(); v != 1 {
+			t.Fatal("expect 1 but get ", v)
+		}
+		if v, err := q.PopContext(context.Background()); err != nil || v != 2 {
+			t.Fatal("expect 2 but get ", v, err)
+		}
+		if _, err := q.PopContext(context.Background()); err != container.ErrClosed {
+			t.Fatal("expect ErrClosed but get ", err)
+		}
+	})
+
+	t.Run("mpmc", func(t *testing.T) {
+		q := container.NewRingQueue[int](64)
+		const producers, consumers, n = 4, 4, 10000
+		var sum int64
+		var count int64
+		var pwg, cwg sync.WaitGroup
+		for p := 0; p < producers; p++ {
+			pwg.Add(1)
+			go func() {
+				defer pwg.Done()
+				for i := 1; i <= n; i++ {
+					q.Push(i)
+				}
+			}()
+		}
+		for c := 0; c < consumers; c++ {
+			cwg.Add(1)
+			go func() {
+				defer cwg.Done()
+				for {
+					v, err := q.PopContext(context.Background())
+					if err != nil {
+						return
+					}
+					atomic.AddInt64(&sum, int64(v))
+					atomic.AddInt64(&count, 1)
+				}
+			}()
+		}
+		pwg.Wait()
+		q.Close()
+		cwg.Wait()
+		if count != producers*n || sum != producers*n*(n+1)/2 {
+			t.Fatal("expect all items consumed once but get ", count, sum)
+		}
+	})
+}
+
+func BenchmarkRingQueueVsBlockQueue(b *testing.B) {
+	b.Run("RingQueue/parallel", func(b *testing.B) {
+		q := container.NewRingQueue[int](1024)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Push(1)
+				q.Pop()
+			}
+		})
+	})
+	b.Run("BlockQueueOf/parallel", func(b *testing.B) {
+		q := container.NewBlockQueueOf[int](1024)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+				q.Dequeue()
+			}
+		})
+	})
+	b.Run("BlockQueue/parallel", func(b *testing.B) {
+		q := container.NewBlockQueue(1024)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+				q.Dequeue()
+			}
+		})
+	})
+	b.Run("RingQueue/4p4c", func(b *testing.B) {
+		q := container.NewRingQueue[int](1024)
+		benchmarkProducerConsumer(b, func(v int) { q.Push(v) }, func() { q.Pop() })
+	})
+	b.Run("BlockQueue/4p4c", func(b *testing.B) {
+		q := container.NewBlockQueue(1024)
+		benchmarkProducerConsumer(b, func(v int) { q.Enqueue(v) }, func() { q.Dequeue() })
+	})
+}
+
+func benchmarkProducerConsumer(b *testing.B, push func(v int), pop func()) {
+	const workers = 4
+	per := b.N/workers + 1
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < per; j++ {
+				push(j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < per; j++ {
+				pop()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -6,8 +6,13 @@
 package test
 
 import (
+	"fmt"
 	"github.com/xfali/goutils/v2/container/skiplist"
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
+	"time"
 )
 
 func TestSkipList(t *testing.T) {
@@ -77,3 +82,225 @@ func TestSkipList(t *testing.T) {
 		t.Fatal("empty list last not nil!")
 	}
 }
+
+func TestSkipListOf(t *testing.T) {
+	t.Run("ordered", func(t *testing.T) {
+		slist := skiplist.NewOf[int, string]()
+		if slist.First() != nil || slist.Last() != nil {
+			t.Fatal("empty list must have no iterator")
+		}
+		for _, i := range []int{3, -1, 2, 10, -2, 7} {
+			slist.Set(i, fmt.Sprintf("s%d", i))
+		}
+		slist.Set(2, "s2-2")
+		if slist.Len() != 6 {
+			t.Fatal("expect 6 but get ", slist.Len())
+		}
+		if v := slist.Get(2); v != "s2-2" {
+			t.Fatal("expect s2-2 but get ", v)
+		}
+		if _, ok := slist.Lookup(100); ok {
+			t.Fatal("100 must not exist")
+		}
+		if !slist.Delete(3) || slist.Delete(3) {
+			t.Fatal("delete 3 failed")
+		}
+
+		expect := []int{-2, -1, 2, 7, 10}
+		i := 0
+		for it := slist.First(); it != nil; it = it.Next() {
+			k, v := it.KeyValue()
+			if k != expect[i] || v != slist.Get(k) {
+				t.Fatal("expect ", expect[i], " but get ", k, v)
+			}
+			i++
+		}
+		if i != len(expect) {
+			t.Fatal("expect ", len(expect), " but get ", i)
+		}
+		if it := slist.FirstNear(3); it == nil || it.Key() != 7 {
+			t.Fatal("FirstNear 3 must be 7")
+		}
+		if it := slist.Last(); it == nil || it.Key() != 10 {
+			t.Fatal("last must be 10")
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		slist := skiplist.NewOf[string, int]()
+		slist.Set("b", 2)
+		slist.Set("c", 3)
+		slist.Set("a", 1)
+		values := slist.Values(-1)
+		for i, v := range values {
+			if v != i+1 {
+				t.Fatal("expect ", i+1, " but get ", v)
+			}
+		}
+	})
+
+	t.Run("float NaN", func(t *testing.T) {
+		slist := skiplist.NewOf[float64, int]()
+		slist.Set(1.5, 1)
+		slist.Set(math.NaN(), 0)
+		slist.Set(-1.5, -1)
+		if v, ok := slist.Lookup(math.NaN()); !ok || v != 0 {
+			t.Fatal("NaN must be found")
+		}
+		if slist.First().Value() != 0 {
+			t.Fatal("NaN must be the first")
+		}
+	})
+
+	t.Run("custom compare", func(t *testing.T) {
+		type point struct {
+			x, y int
+		}
+		slist := skiplist.NewOfFunc[point, string](func(a, b point) int {
+			if a.x != b.x {
+				return skiplist.CompareOrdered(a.x, b.x)
+			}
+			return skiplist.CompareOrdered(a.y, b.y)
+		})
+		slist.Set(point{1, 2}, "1-2")
+		slist.Set(point{0, 5}, "0-5")
+		slist.Set(point{1, 1}, "1-1")
+		expect := []string{"0-5", "1-1", "1-2"}
+		i := 0
+		for it := slist.First(); it != nil; it = it.Next() {
+			if it.Value() != expect[i] {
+				t.Fatal("expect ", expect[i], " but get ", it.Value())
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSkipListSet(b *testing.B) {
+	slist := skiplist.New(skiplist.SetKeyCompareInt())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		slist.Set(i%100000, i)
+	}
+}
+
+func BenchmarkSkipListOfSet(b *testing.B) {
+	slist := skiplist.NewOf[int, int]()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		slist.Set(i%100000, i)
+	}
+}
+
+func TestSkipListOfRange(t *testing.T) {
+	slist := skiplist.NewOf[int, int]()
+	for i := 0; i < 100; i++ {
+		slist.Set(i*2, i)
+	}
+
+	collect := func(from, to int, flag skiplist.RangeFlag, rev bool) []int {
+		var ret []int
+		f := func(key int, value int) bool {
+			ret = append(ret, key)
+			return true
+		}
+		if rev {
+			slist.RevRange(from, to, flag, f)
+		} else {
+			slist.Range(from, to, flag, f)
+		}
+		return ret
+	}
+	check := func(name string, get []int, expect ...int) {
+		if fmt.Sprint(get) != fmt.Sprint(expect) {
+			t.Fatalf("%s expect %v but get %v", name, expect, get)
+		}
+	}
+
+	check("include", collect(10, 16, skiplist.RangeInclude, false), 10, 12, 14, 16)
+	check("exclude from", collect(10, 16, skiplist.RangeExcludeFrom, false), 12, 14, 16)
+	check("exclude to", collect(10, 16, skiplist.RangeExcludeTo, false), 10, 12, 14)
+	check("exclude", collect(10, 16, skiplist.RangeExclude, false), 12, 14)
+	check("not exist bounds", collect(9, 15, skiplist.RangeInclude, false), 10, 12, 14)
+	check("rev include", collect(10, 16, skiplist.RangeInclude, true), 16, 14, 12, 10)
+	check("rev exclude", collect(10, 16, skiplist.RangeExclude, true), 14, 12)
+	check("empty", collect(11, 11, skiplist.RangeInclude, false))
+	check("out of range", collect(1000, 2000, skiplist.RangeInclude, true))
+
+	if n := slist.Count(10, 16, skiplist.RangeInclude); n != 4 {
+		t.Fatal("expect 4 but get ", n)
+	}
+	if n := slist.Count(10, 16, skiplist.RangeExclude); n != 2 {
+		t.Fatal("expect 2 but get ", n)
+	}
+	if n := slist.Count(16, 10, skiplist.RangeInclude); n != 0 {
+		t.Fatal("expect 0 but get ", n)
+	}
+
+	if r := slist.Rank(20); r != 10 {
+		t.Fatal("expect 10 but get ", r)
+	}
+	if r := slist.Rank(21); r != -1 {
+		t.Fatal("expect -1 but get ", r)
+	}
+	if it := slist.ByRank(10); it == nil || it.Key() != 20 {
+		t.Fatal("rank 10 must be 20")
+	}
+	if it := slist.ByRank(100); it != nil {
+		t.Fatal("rank 100 must be nil")
+	}
+
+	if n := slist.DeleteRange(10, 16, skiplist.RangeExcludeTo); n != 3 {
+		t.Fatal("expect 3 but get ", n)
+	}
+	check("after delete", collect(0, 20, skiplist.RangeInclude, false), 0, 2, 4, 6, 8, 16, 18, 20)
+	if r := slist.Rank(16); r != 5 {
+		t.Fatal("expect 5 but get ", r)
+	}
+
+	var keys []int
+	for it := slist.Last(); it != nil; it = it.Prev() {
+		keys = append(keys, it.Key())
+	}
+	if len(keys) != slist.Len() || keys[0] != 198 || keys[len(keys)-1] != 0 {
+		t.Fatal("backward iterate failed: ", keys)
+	}
+	if it := slist.LastNear(15); it == nil || it.Key() != 8 {
+		t.Fatal("LastNear 15 must be 8")
+	}
+}
+
+func TestSkipListOfRank(t *testing.T) {
+	slist := skiplist.NewOf[int, struct{}]()
+	model := map[int]struct{}{}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < 5000; i++ {
+		k := r.Intn(1000)
+		if r.Intn(3) == 0 {
+			slist.Delete(k)
+			delete(model, k)
+		} else {
+			slist.Set(k, struct{}{})
+			model[k] = struct{}{}
+		}
+	}
+	sorted := make([]int, 0, len(model))
+	for k := range model {
+		sorted = append(sorted, k)
+	}
+	sort.Ints(sorted)
+	if slist.Len() != len(sorted) {
+		t.Fatal("expect ", len(sorted), " but get ", slist.Len())
+	}
+	for i, k := range sorted {
+		if r := slist.Rank(k); r != i {
+			t.Fatal("key ", k, " expect rank ", i, " but get ", r)
+		}
+		if it := slist.ByRank(i); it == nil || it.Key() != k {
+			t.Fatal("rank ", i, " expect key ", k)
+		}
+	}
+	if last := slist.Last(); last == nil || last.Key() != sorted[len(sorted)-1] {
+		t.Fatal("last not match")
+	}
+}
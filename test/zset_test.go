@@ -0,0 +1,150 @@
+// Copyright (C) 2019-2020, Xiongfa Li.
+// @author xiongfa.li
+// @version V1.0
+// Description:
+
+package test
+
+import (
+	"fmt"
+	"github.com/xfali/goutils/v2/container/skiplist"
+	"github.com/xfali/goutils/v2/container/zset"
+	"math"
+	"testing"
+)
+
+func members(zs []zset.Z[string]) string {
+	ret := ""
+	for _, z := range zs {
+		ret += fmt.Sprintf("%s:%v ", z.Member, z.Score)
+	}
+	return ret
+}
+
+func mustAdd[M skiplist.Ordered](t *testing.T, z *zset.ZSet[M], member M, score float64) bool {
+	added, err := z.Add(member, score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return added
+}
+
+func TestZSet(t *testing.T) {
+	z := zset.New[string]()
+	if !mustAdd(t, z, "a", 1) || !mustAdd(t, z, "c", 2) || !mustAdd(t, z, "b", 2) || !mustAdd(t, z, "d", 3) {
+		t.Fatal("add must return true")
+	}
+	if mustAdd(t, z, "a", 1) {
+		t.Fatal("add exists member must return false")
+	}
+	if z.Card() != 4 {
+		t.Fatal("expect 4 but get ", z.Card())
+	}
+
+	// 分数相同时按成员字典序排序
+	if s := members(z.RangeByRank(0, -1)); s != "a:1 b:2 c:2 d:3 " {
+		t.Fatal("range by rank not match: ", s)
+	}
+	if s := members(z.RevRange(0, 1)); s != "d:3 c:2 " {
+		t.Fatal("rev range not match: ", s)
+	}
+	if s := members(z.RangeByRank(-2, 100)); s != "c:2 d:3 " {
+		t.Fatal("range by negative rank not match: ", s)
+	}
+	if s := z.RangeByRank(3, 1); len(s) != 0 {
+		t.Fatal("range must be empty")
+	}
+	if r := z.Rank("c"); r != 2 {
+		t.Fatal("expect 2 but get ", r)
+	}
+	if r := z.RevRank("c"); r != 1 {
+		t.Fatal("expect 1 but get ", r)
+	}
+	if r := z.Rank("x"); r != -1 {
+		t.Fatal("expect -1 but get ", r)
+	}
+
+	if s := members(z.RangeByScore(2, 3, skiplist.RangeInclude)); s != "b:2 c:2 d:3 " {
+		t.Fatal("range by score not match: ", s)
+	}
+	if s := members(z.RangeByScore(2, 3, skiplist.RangeExcludeFrom)); s != "d:3 " {
+		t.Fatal("range by score exclude min not match: ", s)
+	}
+	if s := members(z.RevRangeByScore(1, 2, skiplist.RangeInclude)); s != "c:2 b:2 a:1 " {
+		t.Fatal("rev range by score not match: ", s)
+	}
+	if n := z.Count(1, 2, skiplist.RangeInclude); n != 3 {
+		t.Fatal("expect 3 but get ", n)
+	}
+	if n := z.Count(1, 2, skiplist.RangeExclude); n != 0 {
+		t.Fatal("expect 0 but get ", n)
+	}
+
+	if score, err := z.IncrBy("a", 2.5); err != nil || score != 3.5 {
+		t.Fatal("expect 3.5 but get ", score, err)
+	}
+	if score, ok := z.Score("a"); !ok || score != 3.5 {
+		t.Fatal("expect 3.5 but get ", score)
+	}
+	if score, err := z.IncrBy("e", -1); err != nil || score != -1 {
+		t.Fatal("expect -1 but get ", score, err)
+	}
+	if s := members(z.RangeByRank(0, -1)); s != "e:-1 b:2 c:2 d:3 a:3.5 " {
+		t.Fatal("range after incr not match: ", s)
+	}
+
+	if n := z.Rem("b", "x"); n != 1 {
+		t.Fatal("expect 1 but get ", n)
+	}
+	if s := members(z.PopMin(2)); s != "e:-1 c:2 " {
+		t.Fatal("pop min not match: ", s)
+	}
+	if s := members(z.PopMax(1)); s != "a:3.5 " {
+		t.Fatal("pop max not match: ", s)
+	}
+	if z.Card() != 1 || z.Rank("d") != 0 {
+		t.Fatal("only d must be left")
+	}
+	if s := z.PopMin(0); len(s) != 0 {
+		t.Fatal("pop 0 must be empty")
+	}
+}
+
+func TestZSetNaN(t *testing.T) {
+	z := zset.New[string]()
+	if _, err := z.Add("a", math.NaN()); err != zset.ErrNaN {
+		t.Fatal("expect ErrNaN but get ", err)
+	}
+	if z.Card() != 0 {
+		t.Fatal("NaN must not be added")
+	}
+	mustAdd(t, z, "inf", math.Inf(-1))
+	if _, err := z.IncrBy("inf", math.Inf(1)); err != zset.ErrNaN {
+		t.Fatal("expect ErrNaN but get ", err)
+	}
+	if score, ok := z.Score("inf"); !ok || !math.IsInf(score, -1) {
+		t.Fatal("score must not change but get ", score)
+	}
+	if _, err := z.IncrBy("b", math.NaN()); err != zset.ErrNaN || z.Card() != 1 {
+		t.Fatal("expect ErrNaN without adding but get ", err, z.Card())
+	}
+}
+
+func TestZSetRemRangeByScore(t *testing.T) {
+	z := zset.New[int]()
+	for i := 0; i < 10; i++ {
+		z.Add(i, float64(i%5))
+	}
+	if n := z.RemRangeByScore(1, 3, skiplist.RangeExcludeTo); n != 4 {
+		t.Fatal("expect 4 but get ", n)
+	}
+	if z.Card() != 6 {
+		t.Fatal("expect 6 but get ", z.Card())
+	}
+	if _, ok := z.Score(6); ok {
+		t.Fatal("6 must be removed")
+	}
+	if score, ok := z.Score(3); !ok || score != 3 {
+		t.Fatal("3 must exist")
+	}
+}